@@ -2,13 +2,23 @@ package air
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
 	"path"
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 )
 
 type (
@@ -20,6 +30,14 @@ type (
 		contextPool *sync.Pool
 		server      *server
 		router      *router
+		hosts       []*host
+		versions    []*version
+		routeNames  map[string]*Route
+
+		startHooks    []func() error
+		shutdownHooks []func()
+		ready         int32
+		upgrading     int32
 
 		Config           *Config
 		Logger           Logger
@@ -28,6 +46,20 @@ type (
 		Renderer         Renderer
 		Coffer           Coffer
 		HTTPErrorHandler HTTPErrorHandler
+
+		// NotFoundHandler handles the HTTP requests that have no route matched. The
+		// package-level `NotFoundHandler` will be used if it is nil.
+		NotFoundHandler Handler
+
+		// MethodNotAllowedHandler handles the HTTP requests whose methods are not allowed by
+		// the matched routes. The package-level `MethodNotAllowedHandler` will be used if it
+		// is nil.
+		MethodNotAllowedHandler Handler
+
+		// UnknownHostHandler handles the HTTP requests for the hosts that are not registered by
+		// the `Host()` when there is any. The HTTP requests will be routed by the routes
+		// registered in the `Air` instance itself if it is nil.
+		UnknownHostHandler Handler
 	}
 
 	// Handler defines a function to serve HTTP requests.
@@ -70,14 +102,19 @@ const (
 
 // HTTP methods
 const (
-	GET    = "GET"
-	POST   = "POST"
-	PUT    = "PUT"
-	DELETE = "DELETE"
+	GET     = "GET"
+	HEAD    = "HEAD"
+	POST    = "POST"
+	PUT     = "PUT"
+	PATCH   = "PATCH"
+	DELETE  = "DELETE"
+	CONNECT = "CONNECT"
+	OPTIONS = "OPTIONS"
+	TRACE   = "TRACE"
 )
 
 // For easy for-range
-var methods = [4]string{GET, POST, PUT, DELETE}
+var methods = [9]string{GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, TRACE}
 
 // HTTP headers
 const (
@@ -128,6 +165,11 @@ const (
 	HeaderReferer                         = "Referer"
 	HeaderReferrerPolicy                  = "Referrer-Policy"
 	HeaderRetryAfter                      = "Retry-After"
+	HeaderSecWebSocketAccept              = "Sec-WebSocket-Accept"
+	HeaderSecWebSocketExtensions          = "Sec-WebSocket-Extensions"
+	HeaderSecWebSocketKey                 = "Sec-WebSocket-Key"
+	HeaderSecWebSocketProtocol            = "Sec-WebSocket-Protocol"
+	HeaderSecWebSocketVersion             = "Sec-WebSocket-Version"
 	HeaderServer                          = "Server"
 	HeaderSetCookie                       = "Set-Cookie"
 	HeaderStrictTransportSecurity         = "Strict-Transport-Security"
@@ -155,11 +197,14 @@ const (
 
 // HTTP errors
 var (
+	ErrBadRequest            = NewHTTPError(http.StatusBadRequest)            // 400
 	ErrUnauthorized          = NewHTTPError(http.StatusUnauthorized)          // 401
+	ErrForbidden             = NewHTTPError(http.StatusForbidden)             // 403
 	ErrNotFound              = NewHTTPError(http.StatusNotFound)              // 404
 	ErrMethodNotAllowed      = NewHTTPError(http.StatusMethodNotAllowed)      // 405
 	ErrRequestEntityTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge) // 413
 	ErrUnsupportedMediaType  = NewHTTPError(http.StatusUnsupportedMediaType)  // 415
+	ErrUpgradeRequired       = NewHTTPError(http.StatusUpgradeRequired)       // 426
 
 	ErrInternalServerError = NewHTTPError(http.StatusInternalServerError) // 500
 	ErrBadGateway          = NewHTTPError(http.StatusBadGateway)          // 502
@@ -167,6 +212,12 @@ var (
 	ErrGatewayTimeout      = NewHTTPError(http.StatusGatewayTimeout)      // 504
 
 	ErrInvalidRedirectCode = errors.New("invalid redirect status code")
+
+	// ErrNextRoute can be returned by a `Handler` or a `Gas` to decline the current HTTP
+	// request. The router will resume its search from the last backtracking point, trying the
+	// remaining param routes and then the catch-all route of each level, and hand the current
+	// HTTP request to the next matched route, or to the not-found `Handler` if there is not.
+	ErrNextRoute = errors.New("next route")
 )
 
 // HTTP error handlers
@@ -180,6 +231,25 @@ var (
 	}
 )
 
+// ReadinessHandler responds the 200 if the `Air#Ready()` reports true, otherwise it returns the
+// `ErrServiceUnavailable`. It's not registered by default, register it when needed:
+//
+//	a.GET("/healthz/ready", air.ReadinessHandler)
+func ReadinessHandler(c *Context) error {
+	if !c.Air.Ready() {
+		return ErrServiceUnavailable
+	}
+	return c.String("ready")
+}
+
+// OPTIONSHandler is the handler that answers the OPTIONS requests automatically when there is no
+// OPTIONS route registered for the path.
+var OPTIONSHandler = func(c *Context) error {
+	c.Response.Header().Set(HeaderAllow, strings.Join(c.AllowedMethods(), ", "))
+	c.Response.WriteHeader(http.StatusNoContent)
+	return c.NoContent()
+}
+
 // New returns a pointer of a new instance of the `Air`.
 func New() *Air {
 	a := &Air{}
@@ -191,6 +261,7 @@ func New() *Air {
 	}
 	a.server = newServer(a)
 	a.router = newRouter(a)
+	a.routeNames = make(map[string]*Route)
 
 	a.Config = NewConfig("config.toml")
 	a.Logger = newLogger(a)
@@ -206,73 +277,195 @@ func New() *Air {
 // Precontain adds the gases to the chain which is perform before the router.
 func (a *Air) Precontain(gases ...Gas) {
 	a.pregases = append(a.pregases, gases...)
+	a.server.compose()
 }
 
 // Contain adds the gases to the chain which is perform after the router.
 func (a *Air) Contain(gases ...Gas) {
 	a.gases = append(a.gases, gases...)
+	a.server.compose()
 }
 
 // GET registers a new GET route for the path with the matching h in the router with the optional
+// route-level gases. It returns the registered `Route` which can be named by the `Route#Name()`.
+func (a *Air) GET(path string, h Handler, gases ...Gas) *Route {
+	return a.add(GET, path, h, gases...)
+}
+
+// HEAD registers a new HEAD route for the path with the matching h in the router with the optional
 // route-level gases.
-func (a *Air) GET(path string, h Handler, gases ...Gas) {
-	a.add(GET, path, h, gases...)
+//
+// The HEAD requests will be served by the GET route of the same path with the body discarded if
+// there is no HEAD route registered for it.
+func (a *Air) HEAD(path string, h Handler, gases ...Gas) *Route {
+	return a.add(HEAD, path, h, gases...)
 }
 
 // POST registers a new POST route for the path with the matching h in the router with the optional
 // route-level gases.
-func (a *Air) POST(path string, h Handler, gases ...Gas) {
-	a.add(POST, path, h, gases...)
+func (a *Air) POST(path string, h Handler, gases ...Gas) *Route {
+	return a.add(POST, path, h, gases...)
 }
 
 // PUT registers a new PUT route for the path with the matching h in the router with the optional
 // route-level gases.
-func (a *Air) PUT(path string, h Handler, gases ...Gas) {
-	a.add(PUT, path, h, gases...)
+func (a *Air) PUT(path string, h Handler, gases ...Gas) *Route {
+	return a.add(PUT, path, h, gases...)
+}
+
+// PATCH registers a new PATCH route for the path with the matching h in the router with the
+// optional route-level gases.
+func (a *Air) PATCH(path string, h Handler, gases ...Gas) *Route {
+	return a.add(PATCH, path, h, gases...)
 }
 
 // DELETE registers a new DELETE route for the path with the matching h in the router with the
 // optional route-level gases.
-func (a *Air) DELETE(path string, h Handler, gases ...Gas) {
-	a.add(DELETE, path, h, gases...)
+func (a *Air) DELETE(path string, h Handler, gases ...Gas) *Route {
+	return a.add(DELETE, path, h, gases...)
+}
+
+// CONNECT registers a new CONNECT route for the path with the matching h in the router with the
+// optional route-level gases.
+func (a *Air) CONNECT(path string, h Handler, gases ...Gas) *Route {
+	return a.add(CONNECT, path, h, gases...)
+}
+
+// OPTIONS registers a new OPTIONS route for the path with the matching h in the router with the
+// optional route-level gases.
+//
+// The OPTIONS requests will be answered automatically if there is no OPTIONS route registered for
+// the path.
+func (a *Air) OPTIONS(path string, h Handler, gases ...Gas) *Route {
+	return a.add(OPTIONS, path, h, gases...)
+}
+
+// TRACE registers a new TRACE route for the path with the matching h in the router with the
+// optional route-level gases.
+func (a *Air) TRACE(path string, h Handler, gases ...Gas) *Route {
+	return a.add(TRACE, path, h, gases...)
+}
+
+// Any registers a new route for all the HTTP methods for the path with the matching h in the router
+// with the optional route-level gases. It returns all the registered `Route`s.
+func (a *Air) Any(path string, h Handler, gases ...Gas) []*Route {
+	return a.Match(methods[:], path, h, gases...)
+}
+
+// Match registers a new route for the multiple HTTP methods for the path with the matching h in the
+// router with the optional route-level gases. It returns all the registered `Route`s.
+func (a *Air) Match(methods []string, path string, h Handler, gases ...Gas) []*Route {
+	rs := make([]*Route, 0, len(methods))
+	for _, m := range methods {
+		rs = append(rs, a.add(m, path, h, gases...))
+	}
+	return rs
 }
 
 // Static registers a new route with the path prefix to serve the static files from the provided
 // root directory.
-func (a *Air) Static(prefix, root string) {
-	a.GET(prefix+"*", func(c *Context) error {
+func (a *Air) Static(prefix, root string) *Route {
+	return a.GET(prefix+"*", func(c *Context) error {
 		return c.File(path.Join(root, c.Param("*")))
 	})
 }
 
 // File registers a new route with the path to serve a static file.
-func (a *Air) File(path, file string) {
-	a.GET(path, func(c *Context) error {
+func (a *Air) File(path, file string) *Route {
+	return a.GET(path, func(c *Context) error {
 		return c.File(file)
 	})
 }
 
+// Mount registers the catch-all routes with the prefix for all the HTTP methods to serve the h with
+// the optional route-level gases. The prefix will be stripped from the `Request#URL.Path` and the
+// `Request#URL.RawPath` before they being passed to the h.
+//
+// Another `Air` instance can also be mounted as the h, its pregases, gases and `HTTPErrorHandler`
+// will be kept.
+func (a *Air) Mount(prefix string, h http.Handler, gases ...Gas) []*Route {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mh := mountHandler(prefix, h)
+	rs := a.Any(prefix+"/*", mh, gases...)
+	if prefix != "" {
+		rs = append(rs, a.Any(prefix, mh, gases...)...)
+	}
+	return rs
+}
+
+// ServeHTTP implements the `http.Handler`. It makes the a can be mounted by the `Mount()` of
+// another `Air` instance.
+func (a *Air) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	a.server.ServeHTTP(rw, req)
+}
+
 // add registers a new route for the path with the method and the matching h in the router with the
 // optional route-level gases.
-func (a *Air) add(method, path string, h Handler, gases ...Gas) {
-	hn := handlerName(h)
+func (a *Air) add(method, path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(method, path, h, gases)
+}
+
+// Routes returns the `RouteInfo`s of all the registered routes sorted by the host, the API version,
+// the path and the method.
+func (a *Air) Routes() []RouteInfo {
+	ris := make([]RouteInfo, 0, len(a.router.routes))
+	for _, r := range a.router.routes {
+		ris = append(ris, r.info())
+	}
 
-	a.router.add(method, path, func(c *Context) error {
-		h := h
-		for i := len(gases) - 1; i >= 0; i-- {
-			h = gases[i](h)
+	for _, h := range a.hosts {
+		for _, r := range h.router.routes {
+			ris = append(ris, r.info())
 		}
-		return h(c)
-	})
+	}
+
+	for _, v := range a.versions {
+		for _, r := range v.router.routes {
+			ris = append(ris, r.info())
+		}
+	}
+
+	sort.Sort(routeInfos(ris))
+
+	return ris
+}
+
+// URLFor returns an URL path generated from the route named by the name with the params and the
+// optional query. The param values will be escaped, the "*" param can have the '/'. The URL path
+// of the route registered by the `Version()` is prefixed with its API version (e.g. "/v2/users")
+// if the API version can be requested by the path prefix.
+//
+// It returns an error if the route does not exist, or if any param is missing or does not satisfy
+// its constraint.
+func (a *Air) URLFor(name string, params Map, query url.Values) (string, error) {
+	r := a.routeNames[name]
+	if r == nil {
+		return "", fmt.Errorf("the route named %q does not exist", name)
+	}
+
+	u, err := r.url(params)
+	if err != nil {
+		return "", err
+	}
+
+	if v := r.router.version; isVersion(v) {
+		if u == "/" {
+			u = ""
+		}
+		u = "/v" + v + u
+	}
 
-	a.router.routes[method+path] = &route{
-		method:  method,
-		path:    path,
-		handler: hn,
+	if len(query) > 0 {
+		u += "?" + query.Encode()
 	}
+
+	return u, nil
 }
 
 // URL returns an URL generated from the h with the optional params.
+//
+// Deprecated: The h is matched by its func name, which is not reliable for the closures and the
+// handlers shared by several routes. Use the `URLFor()` instead.
 func (a *Air) URL(h Handler, params ...interface{}) string {
 	url := &bytes.Buffer{}
 	hn := handlerName(h)
@@ -302,37 +495,185 @@ func (a *Air) URL(h Handler, params ...interface{}) string {
 }
 
 // Serve starts the HTTP server.
+//
+// The startup sequence is: initializing the `Minifier`, the `Renderer` and the `Coffer` in order,
+// calling the hooks registered by the `OnStart()` in order, and then listening. The errors
+// occurred in the startup sequence will be logged, or be returned immediately if the
+// `Config#StartupAbortOnError` is true. The `Ready()` reports true once the startup sequence is
+// finished.
+//
+// It listens on the `Config#Address`, or on the listener inherited from the systemd socket
+// activation if the "LISTEN_FDS" and the "LISTEN_PID" are set for the current process.
 func (a *Air) Serve() error {
+	return a.serve(nil)
+}
+
+// ServeListener starts the HTTP server like the `Serve()`, but accepts the HTTP connections on the
+// l instead of listening on the `Config#Address`. The l will be closed when the HTTP server is
+// closed.
+func (a *Air) ServeListener(l net.Listener) error {
+	return a.serve(l)
+}
+
+// serve starts the HTTP server on the l. It listens by itself if the l is nil.
+func (a *Air) serve(l net.Listener) error {
 	if a.Config.DebugMode {
 		a.Config.LoggerEnabled = true
 		a.Logger.Debug("serving in debug mode")
 	}
 
-	go func() {
-		if err := a.Minifier.Init(); err != nil {
-			a.Logger.Error(err)
+	if err := a.start(); err != nil {
+		a.closeComponents()
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	if a.Config.GracefulShutdownTimeout > 0 {
+		sc := make(chan os.Signal, 1)
+		signal.Notify(sc, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sc)
+
+		go a.shutdownOnSignal(sc, done)
+	}
+
+	if a.Config.UpgradeEnabled && len(upgradeSignals) > 0 {
+		uc := make(chan os.Signal, 1)
+		signal.Notify(uc, upgradeSignals...)
+		defer signal.Stop(uc)
+
+		go a.upgradeOnSignal(uc, done)
+	}
+
+	err := a.server.serve(l)
+	if err == http.ErrServerClosed {
+		if atomic.LoadInt32(&a.server.shuttingDown) == 1 {
+			<-a.server.shutdownDone
 		}
+		return nil
+	}
 
-		if err := a.Renderer.Init(); err != nil {
-			a.Logger.Error(err)
+	return err
+}
+
+// Close closes the HTTP server and the WebSocket connections immediately. The `Minifier`, the
+// `Renderer` and the `Coffer` that implement the `io.Closer` will be closed after that.
+func (a *Air) Close() error {
+	err := a.server.Close()
+	a.server.closeWebSockets()
+	if cerr := a.closeComponents(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down the HTTP server without interrupting any active connections. It
+// waits for the active connections to finish until the ctx is done, and then closes the remaining
+// ones. The hooks registered by the `OnShutdown()` will be called after that, and then the
+// `Minifier`, the `Renderer` and the `Coffer` that implement the `io.Closer` will be closed.
+//
+// The WebSocket connections are sent a close frame with the `WebSocketCloseGoingAway`, and are
+// waited to be closed in the same way as the active connections.
+//
+// The `Serve()` will return nil after the `Shutdown()` finishes.
+func (a *Air) Shutdown(ctx context.Context) error {
+	s := a.server
+
+	atomic.StoreInt32(&a.ready, 0)
+	atomic.StoreInt32(&s.shuttingDown, 1)
+
+	err := s.Shutdown(ctx)
+	if werr := s.shutdownWebSockets(ctx); err == nil {
+		err = werr
+	}
+
+	if err != nil {
+		s.Close()
+	}
+
+	s.shutdownOnce.Do(func() {
+		for _, h := range a.shutdownHooks {
+			h()
+		}
+
+		if cerr := a.closeComponents(); err == nil {
+			err = cerr
 		}
 
-		if err := a.Coffer.Init(); err != nil {
+		close(s.shutdownDone)
+	})
+
+	return err
+}
+
+// OnStart registers the hooks that will be called in order by the `Serve()` after the `Minifier`,
+// the `Renderer` and the `Coffer` have been initialized and before the HTTP server starts
+// listening.
+func (a *Air) OnStart(hooks ...func() error) {
+	a.startHooks = append(a.startHooks, hooks...)
+}
+
+// Ready reports whether the startup sequence of the `Serve()` has been finished and the HTTP
+// server is not shutting down. It can be reported by a health endpoint, such as the
+// `ReadinessHandler`.
+func (a *Air) Ready() bool {
+	return atomic.LoadInt32(&a.ready) == 1
+}
+
+// start runs the startup sequence of the `Serve()` except the listening.
+func (a *Air) start() error {
+	inits := []func() error{a.Minifier.Init, a.Renderer.Init, a.Coffer.Init}
+	for _, init := range append(inits, a.startHooks...) {
+		if err := init(); err != nil {
+			if a.Config.StartupAbortOnError {
+				return err
+			}
 			a.Logger.Error(err)
 		}
-	}()
+	}
+	return nil
+}
 
-	return a.server.serve()
+// closeComponents closes the `Minifier`, the `Renderer` and the `Coffer` in order if they
+// implement the `io.Closer`. It returns the first error encountered.
+func (a *Air) closeComponents() error {
+	var err error
+	for _, c := range []interface{}{a.Minifier, a.Renderer, a.Coffer} {
+		if closer, ok := c.(io.Closer); ok {
+			if cerr := closer.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
 }
 
-// Close closes the HTTP server immediately.
-func (a *Air) Close() error {
-	return a.server.Close()
+// OnShutdown registers the hooks that will be called in order when the `Shutdown()` is called,
+// after the active connections have been finished or closed.
+func (a *Air) OnShutdown(hooks ...func()) {
+	a.shutdownHooks = append(a.shutdownHooks, hooks...)
 }
 
-// Shutdown gracefully shuts down the HTTP server without interrupting any active connections.
-func (a *Air) Shutdown(c *Context) error {
-	return a.server.Shutdown(c.Context)
+// shutdownOnSignal shuts down the HTTP server gracefully within the
+// `Config#GracefulShutdownTimeout` when a signal is received from the sc. It stops waiting for the
+// signals when the done is closed.
+func (a *Air) shutdownOnSignal(sc chan os.Signal, done chan struct{}) {
+	select {
+	case s := <-sc:
+		a.Logger.Infof("shutting down gracefully on %v", s)
+
+		ctx, cancel := context.WithTimeout(
+			context.Background(),
+			a.Config.GracefulShutdownTimeout,
+		)
+		defer cancel()
+
+		if err := a.Shutdown(ctx); err != nil {
+			a.Logger.Error(err)
+		}
+	case <-done:
+	}
 }
 
 // handlerName returns the func name of the h.
@@ -348,6 +689,46 @@ func WrapHandler(h http.Handler) Handler {
 	}
 }
 
+// mountHandler returns a `Handler` that serves the h with the HTTP requests whose URL paths are
+// stripped the prefix.
+func mountHandler(prefix string, h http.Handler) Handler {
+	n := strings.Count(prefix, "/")
+	return func(c *Context) error {
+		req := c.Request.Request.WithContext(c.Context)
+
+		u := *req.URL
+		u.Path = stripSegments(u.Path, n)
+		if u.RawPath != "" {
+			u.RawPath = stripSegments(u.RawPath, n)
+		}
+
+		req.URL = &u
+
+		h.ServeHTTP(c.Response, req)
+
+		return nil
+	}
+}
+
+// stripSegments returns a path from the p without the first n segments.
+func stripSegments(p string, n int) string {
+	i := 0
+	for ; n > 0 && i < len(p); n-- {
+		j := strings.IndexByte(p[i+1:], '/')
+		if j < 0 {
+			i = len(p)
+			break
+		}
+		i += j + 1
+	}
+
+	if i >= len(p) {
+		return "/"
+	}
+
+	return p[i:]
+}
+
 // WrapGas wraps the h into the `Gas`.
 func WrapGas(h Handler) Gas {
 	return func(next Handler) Handler {
@@ -384,6 +765,9 @@ func DefaultHTTPErrorHandler(err error, c *Context) {
 	}
 
 	if !c.Response.Written {
+		if he.Code == http.StatusMethodNotAllowed {
+			c.Response.Header().Set(HeaderAllow, strings.Join(c.AllowedMethods(), ", "))
+		}
 		c.Response.StatusCode = he.Code
 		c.String(he.Message)
 	}
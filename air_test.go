@@ -2,12 +2,16 @@ package air
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -88,6 +92,101 @@ func TestAirMethods(t *testing.T) {
 	rec = httptest.NewRecorder()
 	a.server.ServeHTTP(rec, req)
 	assert.Equal(t, DELETE, rec.Body.String())
+
+	a.PATCH(path, func(c *Context) error { return c.String(PATCH) })
+	a.CONNECT(path, func(c *Context) error { return c.String(CONNECT) })
+	a.TRACE(path, func(c *Context) error { return c.String(TRACE) })
+
+	for _, m := range []string{PATCH, CONNECT, TRACE} {
+		req.Method = m
+		rec = httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, m, rec.Body.String())
+	}
+}
+
+func TestAirHEADFallback(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+	path := "/head"
+	req, _ := http.NewRequest(HEAD, path, nil)
+	rec := httptest.NewRecorder()
+
+	a.GET(path, func(c *Context) error { return c.String(GET) })
+
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMETextPlain+CharsetUTF8, rec.Header().Get(HeaderContentType))
+	assert.Empty(t, rec.Body.String())
+
+	a.HEAD(path, func(c *Context) error {
+		c.Response.Header().Set("X-Method", HEAD)
+		return c.NoContent()
+	})
+
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, HEAD, rec.Header().Get("X-Method"))
+}
+
+func TestAirOPTIONS(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+	path := "/options"
+	req, _ := http.NewRequest(OPTIONS, path, nil)
+	rec := httptest.NewRecorder()
+
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	a.POST(path, func(c *Context) error { return c.String(POST) })
+
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "POST, OPTIONS", rec.Header().Get(HeaderAllow))
+
+	a.OPTIONS(path, func(c *Context) error { return c.String(OPTIONS) })
+
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, OPTIONS, rec.Body.String())
+}
+
+func TestAirAnyAndMatch(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+
+	a.Any("/any", func(c *Context) error { return c.String(c.Request.Method) })
+	a.Match([]string{PUT, PATCH}, "/match", func(c *Context) error {
+		return c.String(c.Request.Method)
+	})
+
+	for _, m := range methods {
+		req, _ := http.NewRequest(m, "/any", nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		if m == HEAD {
+			assert.Empty(t, rec.Body.String())
+		} else {
+			assert.Equal(t, m, rec.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest(PATCH, "/match", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, PATCH, rec.Body.String())
+
+	req, _ = http.NewRequest(GET, "/match", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	assert.Panics(t, func() { a.Match([]string{"get"}, "/lowercase", nil) })
+	assert.Panics(t, func() { a.Match([]string{"PROPFIND"}, "/unknown", nil) })
+	assert.Empty(t, a.router.routes["get/lowercase"])
+	assert.Empty(t, a.router.routes["PROPFIND/unknown"])
 }
 
 func TestAirStatic(t *testing.T) {
@@ -134,6 +233,101 @@ func TestAirURL(t *testing.T) {
 	assert.Equal(t, "/foo/bar", a.URL(h, "foo", "bar"))
 }
 
+func TestAirRoutes(t *testing.T) {
+	a := New()
+	h := func(c *Context) error { return c.NoContent() }
+	gas := WrapGas(h)
+
+	a.POST("/users", h)
+	a.GET("/users", h, gas)
+	NewGroup(a, "/api", gas).GET("/posts/:id<int>/*", h).Name("post")
+
+	rs := a.Routes()
+	assert.Len(t, rs, 3)
+
+	assert.Equal(t, "/api/posts/:id<int>/*", rs[0].Path)
+	assert.Equal(t, GET, rs[0].Method)
+	assert.Equal(t, "post", rs[0].Name)
+	assert.Equal(t, []string{"id", "*"}, rs[0].ParamNames)
+	assert.Equal(t, 1, rs[0].GasCount)
+	assert.Equal(t, "/api", rs[0].GroupPrefix)
+
+	assert.Equal(t, GET, rs[1].Method)
+	assert.Equal(t, 1, rs[1].GasCount)
+	assert.Equal(t, POST, rs[2].Method)
+	assert.Equal(t, 0, rs[2].GasCount)
+	assert.Equal(t, handlerName(h), rs[2].HandlerName)
+}
+
+func TestAirURLFor(t *testing.T) {
+	a := New()
+	h := func(c *Context) error { return c.NoContent() }
+
+	a.GET("/users/:id<int>", h).Name("user.show")
+	a.GET("/users/:id<int>/posts/:slug", h).Name("user.post")
+	a.GET("/times/:time<[0-9]{2}:[0-9]{2}>/:tz", h).Name("time")
+	a.Static("/assets/", ".").Name("assets")
+	a.Version("2").GET("/users/:id<int>", h).Name("v2.user.show")
+	a.Version("2").GET("/", h).Name("v2.index")
+	a.Version("beta").GET("/users", h).Name("beta.users")
+	for _, r := range a.Match([]string{PUT, PATCH}, "/users/:id<int>", h) {
+		r.Name("user.show")
+	}
+
+	u, err := a.URLFor("user.show", Map{"id": 1}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/1", u)
+
+	u, err = a.URLFor("user.post", Map{"id": 1, "slug": "a b/c+d"}, url.Values{"page": {"2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/1/posts/a%20b%2Fc%2Bd?page=2", u)
+
+	u, err = a.URLFor("time", Map{"time": "08:00", "tz": "UTC"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/times/08:00/UTC", u)
+
+	u, err = a.URLFor("assets", Map{"*": "css/air style.css"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/assets/css/air%20style.css", u)
+
+	u, err = a.URLFor("v2.user.show", Map{"id": 1}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/v2/users/1", u)
+
+	u, err = a.URLFor("v2.index", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/v2", u)
+
+	u, err = a.URLFor("beta.users", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/users", u)
+
+	_, err = a.URLFor("user.show", Map{"id": "air"}, nil)
+	assert.Error(t, err)
+
+	_, err = a.URLFor("user.post", Map{"id": 1}, nil)
+	assert.Error(t, err)
+
+	_, err = a.URLFor("assets", nil, nil)
+	assert.Error(t, err)
+
+	_, err = a.URLFor("unknown", nil, nil)
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { a.GET("/posts", h).Name("user.show") })
+
+	c := a.contextPool.Get().(*Context)
+	req, _ := http.NewRequest(GET, "http://example.com/", nil)
+	c.feed(req, httptest.NewRecorder())
+
+	u, err = c.AbsoluteURLFor("user.show", Map{"id": 2}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/users/2", u)
+
+	_, err = c.AbsoluteURLFor("unknown", nil, nil)
+	assert.Error(t, err)
+}
+
 func TestAirServe(t *testing.T) {
 	a := New()
 	ok := make(chan struct{})
@@ -205,6 +399,83 @@ func TestAirServeParseTemplatesError(t *testing.T) {
 	assert.NoError(t, a.Close())
 }
 
+func TestAirServeStartupSequence(t *testing.T) {
+	a := New()
+	buf := &bytes.Buffer{}
+
+	a.Logger.SetOutput(buf)
+	a.Config.LoggerEnabled = true
+	a.Config.Address = freeAddress()
+	a.Minifier = &failingMinifier{}
+	a.Renderer = &failingRenderer{}
+	a.Coffer = &failingCoffer{}
+	a.OnStart(func() error {
+		assert.False(t, a.Ready())
+		return errors.New("failingHook")
+	})
+	a.GET("/ready", ReadinessHandler)
+
+	assert.False(t, a.Ready())
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+	assert.True(t, a.Ready())
+
+	log := buf.String()
+	assert.True(t, strings.Index(log, "failingMinifier") < strings.Index(log, "failingRenderer"))
+	assert.True(t, strings.Index(log, "failingRenderer") < strings.Index(log, "failingCoffer"))
+	assert.True(t, strings.Index(log, "failingCoffer") < strings.Index(log, "failingHook"))
+
+	req, _ := http.NewRequest(GET, "/ready", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+	assert.False(t, a.Ready())
+
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestAirServeStartupAbortOnError(t *testing.T) {
+	a := New()
+	a.Config.StartupAbortOnError = true
+	a.Minifier = &failingMinifier{}
+
+	called := false
+	a.OnStart(func() error {
+		called = true
+		return nil
+	})
+
+	err := a.Serve()
+	assert.EqualError(t, err, "failingMinifier")
+	assert.False(t, called)
+	assert.False(t, a.Ready())
+
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	a = New()
+	a.Config.Address = freeAddress()
+	a.Config.TemplateRoot = dir
+	a.Config.StartupAbortOnError = true
+	a.OnStart(func() error {
+		assert.NotNil(t, a.Renderer.(*renderer).watcher)
+		return errors.New("hook failed")
+	})
+
+	assert.EqualError(t, a.Serve(), "hook failed")
+	assert.Nil(t, a.Renderer.(*renderer).watcher)
+}
+
 func TestAirServeTLS(t *testing.T) {
 	cert := `
 -----BEGIN CERTIFICATE-----
@@ -294,7 +565,142 @@ l7j2fuWjNfj9JfnXoP2SEgPG
 	}()
 
 	<-ok
-	assert.NoError(t, a.Shutdown(NewContext(a)))
+	assert.NoError(t, a.Shutdown(context.Background()))
+}
+
+// freeAddress returns a free TCP address on the loopback interface.
+func freeAddress() string {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// waitForServer waits until the address is being listened on.
+func waitForServer(address string) {
+	network := "tcp"
+	if strings.HasPrefix(address, unixAddressPrefix) {
+		network, address = "unix", address[len(unixAddressPrefix):]
+	}
+
+	for i := 0; i < 200; i++ {
+		if c, err := net.Dial(network, address); err == nil {
+			c.Close()
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAirShutdown(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+
+	started := make(chan struct{})
+	a.GET("/", func(c *Context) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return c.String("done")
+	})
+
+	hooks := []string{}
+	a.OnShutdown(func() { hooks = append(hooks, "foo") }, func() {
+		hooks = append(hooks, "bar")
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	body := make(chan string)
+	go func() {
+		res, err := http.Get("http://" + a.Config.Address)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.Equal(t, "done", <-body)
+	assert.NoError(t, <-served)
+	assert.Equal(t, []string{"foo", "bar"}, hooks)
+}
+
+func TestAirShutdownClosesComponents(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+
+	m := &closerMinifier{Minifier: a.Minifier}
+	a.Minifier = m
+
+	hooks := []string{}
+	a.OnShutdown(func() { hooks = append(hooks, "foo") })
+	m.close = func() error {
+		hooks = append(hooks, "minifier")
+		return errors.New("minifier closed")
+	}
+
+	assert.EqualError(t, a.Shutdown(context.Background()), "minifier closed")
+	assert.Equal(t, []string{"foo", "minifier"}, hooks)
+}
+
+func TestAirShutdownTimeout(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+
+	started := make(chan struct{})
+	a.GET("/", func(c *Context) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	go http.Get("http://" + a.Config.Address)
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, a.Shutdown(ctx))
+	assert.NoError(t, <-served)
+}
+
+func TestAirShutdownOnSignal(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.GracefulShutdownTimeout = time.Second
+
+	shutdown := false
+	a.OnShutdown(func() { shutdown = true })
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(os.Interrupt); err != nil {
+		a.Close()
+		t.Skip(err)
+	}
+
+	assert.NoError(t, <-served)
+	assert.True(t, shutdown)
 }
 
 func TestAirServeDebugMode(t *testing.T) {
@@ -337,6 +743,60 @@ func TestAirWrapHandler(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 }
 
+func TestAirMount(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+
+	a.Mount("/debug/", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.URL.Path + " " + req.URL.RawPath))
+	}))
+
+	sub := New()
+	sub.Precontain(WrapGas(func(c *Context) error {
+		c.Response.Header().Set("X-Sub", "pregas")
+		return nil
+	}))
+	sub.HTTPErrorHandler = func(err error, c *Context) {
+		c.Response.WriteHeader(http.StatusTeapot)
+		c.String("sub " + err.Error())
+	}
+	sub.GET("/", func(c *Context) error { return c.String("sub index") })
+	sub.GET("/users/:id", func(c *Context) error { return c.String("sub " + c.Param("id")) })
+
+	a.Mount("/sub", sub)
+
+	cases := []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/debug", http.StatusOK, "/ "},
+		{"/debug/pprof/heap", http.StatusOK, "/pprof/heap "},
+		{"/debug/a%2Fb", http.StatusOK, "/a/b /a%2Fb"},
+		{"/sub", http.StatusOK, "sub index"},
+		{"/sub/users/1", http.StatusOK, "sub 1"},
+		{"/sub/posts", http.StatusTeapot, "sub Not Found"},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(GET, c.target, nil)
+		rec := httptest.NewRecorder()
+		a.ServeHTTP(rec, req)
+		assert.Equal(t, c.code, rec.Code, c.target)
+		assert.Equal(t, c.body, rec.Body.String(), c.target)
+		if strings.HasPrefix(c.target, "/sub") {
+			assert.Equal(t, "pregas", rec.Header().Get("X-Sub"), c.target)
+		}
+	}
+}
+
+func TestAirStripSegments(t *testing.T) {
+	assert.Equal(t, "/heap", stripSegments("/debug/pprof/heap", 2))
+	assert.Equal(t, "/", stripSegments("/debug/pprof/", 2))
+	assert.Equal(t, "/", stripSegments("/debug/pprof", 2))
+	assert.Equal(t, "/debug", stripSegments("/debug", 0))
+}
+
 func TestAirWrapGasError(t *testing.T) {
 	g := WrapGas(func(*Context) error { return ErrInternalServerError })
 	h := g(func(*Context) error { return nil })
@@ -372,3 +832,13 @@ func TestAirDefaultHTTPErrorHandler(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	assert.Equal(t, err.Error(), rec.Body.String())
 }
+
+type closerMinifier struct {
+	Minifier
+
+	close func() error
+}
+
+func (cm *closerMinifier) Close() error {
+	return cm.close()
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -13,6 +14,9 @@ import (
 type (
 	// Coffer is used to provide an `Asset()` method for an `Air` instance for accesses binary
 	// asset files by using the runtime memory.
+	//
+	// If the `Coffer` also implements the `io.Closer`, its `Close()` will be called in the
+	// `Air#Close()` and the `Air#Shutdown()`.
 	Coffer interface {
 		// Init initializes the `Coffer`. It will be called in the `Air#Serve()`.
 		Init() error
@@ -34,8 +38,10 @@ type (
 	coffer struct {
 		air *Air
 
-		assets  map[string]*Asset
-		watcher *fsnotify.Watcher
+		assets       map[string]*Asset
+		watcher      *fsnotify.Watcher
+		watcherDone  chan struct{}
+		watcherMutex sync.Mutex
 	}
 )
 
@@ -86,28 +92,66 @@ func (c *coffer) Init() error {
 		return nil
 	}
 
-	ar, err := filepath.Abs(cfg.AssetRoot)
+	dirs, err := c.load()
 	if err != nil {
 		return err
 	}
 
-	dirs, files, err := walkFiles(ar, cfg.AssetExts)
-	if err != nil {
-		return err
-	}
+	c.watcherMutex.Lock()
+	defer c.watcherMutex.Unlock()
 
 	if c.watcher == nil {
-		if c.watcher, err = fsnotify.NewWatcher(); err != nil {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
 			return err
 		}
 
 		for _, dir := range dirs {
-			if err := c.watcher.Add(dir); err != nil {
+			if err := w.Add(dir); err != nil {
+				w.Close()
 				return err
 			}
 		}
 
-		go c.watchAssets()
+		c.watcher = w
+		c.watcherDone = make(chan struct{})
+
+		go c.watchAssets(w, c.watcherDone)
+	}
+
+	return nil
+}
+
+// Close implements the `io.Closer`. It stops watching the asset files and waits for the watching
+// goroutine to exit. The c can be initialized again after closing.
+func (c *coffer) Close() error {
+	c.watcherMutex.Lock()
+	w, done := c.watcher, c.watcherDone
+	c.watcher, c.watcherDone = nil, nil
+	c.watcherMutex.Unlock()
+
+	if w == nil {
+		return nil
+	}
+
+	err := w.Close()
+	<-done
+
+	return err
+}
+
+// load loads all asset files into the c and returns all dirs of them.
+func (c *coffer) load() ([]string, error) {
+	cfg := c.air.Config
+
+	ar, err := filepath.Abs(cfg.AssetRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, files, err := walkFiles(ar, cfg.AssetExts)
+	if err != nil {
+		return nil, err
 	}
 
 	assets := make(map[string]*Asset)
@@ -115,18 +159,18 @@ func (c *coffer) Init() error {
 	for _, file := range files {
 		fi, err := os.Stat(file)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		b, err := ioutil.ReadFile(file)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if cfg.AssetMinified {
 			if mt := mimeTypeByExt(filepath.Ext(file)); mt != "" {
 				if b, err = c.air.Minifier.Minify(mt, b); err != nil {
-					return err
+					return nil, err
 				}
 			}
 		}
@@ -136,7 +180,7 @@ func (c *coffer) Init() error {
 
 	c.assets = assets
 
-	return nil
+	return dirs, nil
 }
 
 // Asset implements the `Coffer#Asset()` by using the `map[string]*Asset`.
@@ -144,21 +188,31 @@ func (c *coffer) Asset(name string) *Asset {
 	return c.assets[name]
 }
 
-// watchTemplates watchs the changing of all asset files.
-func (c *coffer) watchAssets() {
+// watchAssets watchs the changing of all asset files by using the w until the w is closed. The
+// done will be closed when it returns.
+func (c *coffer) watchAssets(w *fsnotify.Watcher, done chan struct{}) {
+	defer close(done)
 	for {
 		select {
-		case event := <-c.watcher.Events:
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+
 			c.air.Logger.Info(event)
 
 			if event.Op == fsnotify.Create {
-				c.watcher.Add(event.Name)
+				w.Add(event.Name)
 			}
 
-			if err := c.Init(); err != nil {
+			if _, err := c.load(); err != nil {
 				c.air.Logger.Error(err)
 			}
-		case err := <-c.watcher.Errors:
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+
 			c.air.Logger.Error(err)
 		}
 	}
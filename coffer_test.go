@@ -1,6 +1,7 @@
 package air
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -143,3 +144,22 @@ Hello, I am the Air.
 	assert.NotNil(t, a.Coffer.Asset(abs))
 	assert.True(t, a.Coffer.Asset(abs).reader.Len() == len(txt))
 }
+
+func TestCofferClose(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	a := New()
+	a.Config.CofferEnabled = true
+	a.Config.AssetRoot = dir
+
+	c := a.Coffer.(*coffer)
+	assert.NoError(t, c.Init())
+	assert.NotNil(t, c.watcher)
+	done := c.watcherDone
+
+	assert.NoError(t, a.Close())
+	assert.Nil(t, c.watcher)
+	_, ok := <-done
+	assert.False(t, ok)
+}
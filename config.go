@@ -2,6 +2,8 @@ package air
 
 import (
 	"io/ioutil"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -43,13 +45,34 @@ type Config struct {
 	// It's called "log_format" in the config file.
 	LogFormat string
 
-	// Address represents the TCP address that the HTTP server to listen on.
+	// StartupAbortOnError indicates whether the `Air#Serve()` returns immediately when an error
+	// occurs in its startup sequence. The `Minifier`, the `Renderer` and the `Coffer` will be
+	// closed before it returns. The error will only be logged if it is false.
+	//
+	// The default value is false.
+	//
+	// It's called "startup_abort_on_error" in the config file.
+	StartupAbortOnError bool
+
+	// Address represents the TCP address that the HTTP server to listen on. It represents the
+	// path of a Unix domain socket if it starts with the "unix:", such as "unix:/run/air.sock".
+	//
+	// It will be ignored if the `Listeners` is not empty or the HTTP server is started with the
+	// listeners inherited from the systemd socket activation.
 	//
 	// The default value is "localhost:2333".
 	//
 	// It's called "address" in the config file.
 	Address string
 
+	// UnixSocketMode represents the file mode of the Unix domain socket created when the
+	// `Address` starts with the "unix:".
+	//
+	// The default value is 0666.
+	//
+	// It's called "unix_socket_mode" in the config file.
+	UnixSocketMode os.FileMode
+
 	// ReadTimeout represents the maximum duration before timing out read of the HTTP request.
 	//
 	// The default value is 0.
@@ -59,6 +82,16 @@ type Config struct {
 	// **It's unit in the config file is MILLISECONDS.**
 	ReadTimeout time.Duration
 
+	// ReadHeaderTimeout represents the maximum duration before timing out read of the HTTP
+	// request header. The `ReadTimeout` will be used if it is zero.
+	//
+	// The default value is 0.
+	//
+	// It's called "read_header_timeout" in the config file.
+	//
+	// **It's unit in the config file is MILLISECONDS.**
+	ReadHeaderTimeout time.Duration
+
 	// WriteTimeout represents the maximum duration before timing out write of the HTTP
 	// response.
 	//
@@ -69,6 +102,64 @@ type Config struct {
 	// **It's unit in the config file is MILLISECONDS.**
 	WriteTimeout time.Duration
 
+	// IdleTimeout represents the maximum duration to wait for the next HTTP request when the
+	// keep-alives are enabled. The `ReadTimeout` will be used if it is zero.
+	//
+	// The default value is 0.
+	//
+	// It's called "idle_timeout" in the config file.
+	//
+	// **It's unit in the config file is MILLISECONDS.**
+	IdleTimeout time.Duration
+
+	// KeepAliveEnabled indicates whether to enable the HTTP keep-alives.
+	//
+	// The default value is true.
+	//
+	// It's called "keep_alive_enabled" in the config file.
+	KeepAliveEnabled bool
+
+	// MaxConnections represents the maximum number of the connections that the HTTP server keeps
+	// open at the same time across all the listeners. The listeners stop accepting the new
+	// connections until some of the open ones are closed when it is reached. It's unlimited if
+	// it is not greater than zero.
+	//
+	// The default value is 0.
+	//
+	// It's called "max_connections" in the config file.
+	MaxConnections int
+
+	// GracefulShutdownTimeout represents the maximum duration that the HTTP server waits for the
+	// active connections to finish when it is shutting down gracefully. The HTTP server will
+	// be shut down gracefully by the `Air#Shutdown()` when an interrupt or a terminate signal
+	// is received if it is greater than zero.
+	//
+	// The default value is 0.
+	//
+	// It's called "graceful_shutdown_timeout" in the config file.
+	//
+	// **It's unit in the config file is MILLISECONDS.**
+	GracefulShutdownTimeout time.Duration
+
+	// UpgradeEnabled indicates whether to upgrade the binary by the `Air#Upgrade()` when a hangup
+	// or a user-defined 2 signal is received. It's not supported on Windows.
+	//
+	// The default value is false.
+	//
+	// It's called "upgrade_enabled" in the config file.
+	UpgradeEnabled bool
+
+	// UpgradeTimeout represents the maximum duration for the `Air#Upgrade()` to wait for the new
+	// process to be ready. The new process will be killed if it is not ready in time. There is
+	// no limit if it is zero.
+	//
+	// The default value is 30 seconds.
+	//
+	// It's called "upgrade_timeout" in the config file.
+	//
+	// **It's unit in the config file is MILLISECONDS.**
+	UpgradeTimeout time.Duration
+
 	// MaxHeaderBytes represents the maximum number of bytes the HTTP server will read parsing
 	// the HTTP request header's keys and values, including the HTTP request line. It does not
 	// limit the size of the HTTP request body.
@@ -92,6 +183,186 @@ type Config struct {
 	// It's called "tls_key_file" in the config file.
 	TLSKeyFile string
 
+	// TLSCertificates represents the extra pairs of the TLS certificate file and the TLS key file.
+	// They are shared by all the listeners that have the TLS settings and chosen by the server
+	// name indicated by the TLS clients. The first one will be used when the `TLSCertFile` or
+	// the `TLSKeyFile` is empty.
+	//
+	// All the TLS certificate files and the TLS key files will be reloaded atomically once they
+	// are changed.
+	//
+	// The default value is nil.
+	//
+	// It's called "tls_certificates" in the config file, which is an array of tables.
+	TLSCertificates []TLSCertificateConfig
+
+	// TLSMinVersion represents the minimum TLS version that the HTTP server accepts. The valid
+	// values are "1.0", "1.1", "1.2" and "1.3".
+	//
+	// The default value is "1.2".
+	//
+	// It's called "tls_min_version" in the config file.
+	TLSMinVersion string
+
+	// TLSCipherSuites represents the names of the enabled TLS cipher suites for the TLS versions
+	// up to 1.2, such as "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". The default cipher suites of
+	// the Go will be used if it is empty. The cipher suites of the TLS 1.3 are not configurable.
+	//
+	// The default value is nil.
+	//
+	// It's called "tls_cipher_suites" in the config file.
+	TLSCipherSuites []string
+
+	// TLSClientCAFile represents the path of the PEM encoded certificate authorities file that
+	// used to verify the TLS client certificates.
+	//
+	// The default value is "".
+	//
+	// It's called "tls_client_ca_file" in the config file.
+	TLSClientCAFile string
+
+	// TLSClientAuth represents the policy of the TLS client authentication. The valid values are
+	// "none", "request", "require_any", "verify_if_given" and "require_and_verify". The TLS
+	// client certificates are verified by the `TLSClientCAFile` only when it is
+	// "verify_if_given" or "require_and_verify".
+	//
+	// The default value is "none".
+	//
+	// It's called "tls_client_auth" in the config file.
+	TLSClientAuth string
+
+	// H2CEnabled indicates whether to enable the HTTP/2 over the cleartext TCP (h2c) for the
+	// listeners that do not have the TLS settings. Both the prior knowledge and the
+	// "Upgrade: h2c" header are supported.
+	//
+	// The default value is false.
+	//
+	// It's called "h2c_enabled" in the config file.
+	H2CEnabled bool
+
+	// ProxyProtocolEnabled indicates whether to read the PROXY protocol v1 or v2 header sent by
+	// the proxies, such as the HAProxy and the AWS NLB, from the connections of the trusted
+	// sources. The client address in the header will be used as the `Request#RemoteAddr`.
+	//
+	// The header must be received within the `ReadHeaderTimeout`, or the `ReadTimeout` if it
+	// is zero. The connections without the header are served as usual.
+	//
+	// The default value is false.
+	//
+	// It's called "proxy_protocol_enabled" in the config file.
+	ProxyProtocolEnabled bool
+
+	// ProxyProtocolTrustedCIDRs represents the CIDRs of the trusted sources of the PROXY protocol
+	// headers, such as "10.0.0.0/8". None of the TCP sources is trusted if it is empty, so it
+	// must be set for the `ProxyProtocolEnabled` to take effect on the TCP connections. The
+	// connections from the Unix domain sockets are always trusted.
+	//
+	// The default value is nil.
+	//
+	// It's called "proxy_protocol_trusted_cidrs" in the config file.
+	ProxyProtocolTrustedCIDRs []string
+
+	// Listeners represents the listeners that the HTTP server to listen on simultaneously. All
+	// of them share the same router and will be shut down together. The `Address`, the
+	// `TLSCertFile` and the `TLSKeyFile` will be ignored if it is not empty.
+	//
+	// The listeners inherited from the systemd socket activation take the settings of the
+	// listeners at the same index, except the `ListenerConfig#Address`.
+	//
+	// The default value is nil.
+	//
+	// It's called "listeners" in the config file, which is an array of tables.
+	Listeners []ListenerConfig
+
+	// RouterStrictSlash indicates whether the router treats the paths with and without the last
+	// slash as different paths. The routes ending with the "/" can only be registered when it
+	// is true. Otherwise the last slash of the HTTP request path will be dropped before
+	// matching.
+	//
+	// The default value is false.
+	//
+	// It's called "router_strict_slash" in the config file.
+	RouterStrictSlash bool
+
+	// RouterCaseInsensitive indicates whether the router matches the HTTP request path
+	// case-insensitively when there is no route matched case-sensitively.
+	//
+	// The default value is false.
+	//
+	// It's called "router_case_insensitive" in the config file.
+	RouterCaseInsensitive bool
+
+	// RouterRedirectCode represents the HTTP status code used to redirect the non-canonical
+	// HTTP request paths to the canonical ones. A path is non-canonical if it has the "//", or
+	// it has the last slash which is not allowed, or it's matched in a different case. The
+	// non-canonical paths will be served directly if it is zero. It must be one of the 301, 302,
+	// 303, 307 and 308, otherwise the 301 will be used. Usually it is 301 or 308.
+	//
+	// The default value is 0.
+	//
+	// It's called "router_redirect_code" in the config file.
+	RouterRedirectCode int
+
+	// MethodOverrideEnabled indicates whether to enable the `MethodOverrideGas` when the HTTP
+	// server is started.
+	//
+	// The default value is false.
+	//
+	// It's called "method_override_enabled" in the config file.
+	MethodOverrideEnabled bool
+
+	// MethodOverrideMethods represents the methods that the POST requests are allowed to be
+	// overridden to by the `MethodOverrideGas`.
+	//
+	// The default value is ["PUT", "PATCH", "DELETE"].
+	//
+	// It's called "method_override_methods" in the config file.
+	MethodOverrideMethods []string
+
+	// VersionHeader represents the name of the header that used to pick the API version of the
+	// HTTP request for the routes registered by the `Air#Version()`.
+	//
+	// The default value is "X-API-Version".
+	//
+	// It's called "version_header" in the config file.
+	VersionHeader string
+
+	// WebSocketOrigins represents the origins that the WebSocket handshakes are allowed from.
+	// The handshakes whose "Origin" header is present are only allowed from the same origin as
+	// the "Host" header if it is empty. The "*" means all the origins are allowed.
+	//
+	// The default value is nil.
+	//
+	// It's called "websocket_origins" in the config file.
+	WebSocketOrigins []string
+
+	// WebSocketSubprotocols represents the subprotocols that the WebSocket connections support.
+	// The first one of the subprotocols requested by the client that is supported will be
+	// chosen.
+	//
+	// The default value is nil.
+	//
+	// It's called "websocket_subprotocols" in the config file.
+	WebSocketSubprotocols []string
+
+	// WebSocketCompressionEnabled indicates whether to enable the per-message compression
+	// (permessage-deflate) of the WebSocket connections if it is requested by the client.
+	//
+	// The default value is false.
+	//
+	// It's called "websocket_compression_enabled" in the config file.
+	WebSocketCompressionEnabled bool
+
+	// WebSocketMaxMessageBytes represents the maximum number of bytes of a message that the
+	// WebSocket connections will read, after the decompression. The WebSocket connections will
+	// be closed with the `WebSocketCloseMessageTooBig` once it is exceeded. It's unlimited if
+	// it is not greater than zero.
+	//
+	// The default value is 1048576.
+	//
+	// It's called "websocket_max_message_bytes" in the config file.
+	WebSocketMaxMessageBytes int
+
 	// TemplateRoot represents the root directory of the HTML templates. It will be parsed into
 	// the `Renderer`. It works only with the default `Renderer`.
 	//
@@ -173,19 +444,68 @@ type Config struct {
 	Data Map
 }
 
+// TLSCertificateConfig is a pair of the TLS certificate file and the TLS key file for the
+// `Config#TLSCertificates`.
+type TLSCertificateConfig struct {
+	// CertFile represents the path of the TLS certificate file.
+	//
+	// It's called "cert_file" in the config file.
+	CertFile string
+
+	// KeyFile represents the path of the TLS key file.
+	//
+	// It's called "key_file" in the config file.
+	KeyFile string
+}
+
+// ListenerConfig is a set of configs for one of the `Config#Listeners`.
+type ListenerConfig struct {
+	// Address represents the TCP address that the listener to listen on. It represents the path
+	// of a Unix domain socket if it starts with the "unix:".
+	//
+	// It's called "address" in the config file.
+	Address string
+
+	// TLSCertFile represents the path of the TLS certificate file of the listener.
+	//
+	// It's called "tls_cert_file" in the config file.
+	TLSCertFile string
+
+	// TLSKeyFile represents the path of the TLS key file of the listener.
+	//
+	// It's called "tls_key_file" in the config file.
+	TLSKeyFile string
+
+	// HTTPSRedirect indicates whether the listener redirects every HTTP request to the HTTPS
+	// origin with the 308 status code, except the ones whose path starts with the
+	// "/.well-known/". The port of the HTTPS origin is the one of the first listener that has
+	// the TLS settings.
+	//
+	// It's called "https_redirect" in the config file.
+	HTTPSRedirect bool
+}
+
 // DefaultConfig is the default instance of the `Config`.
 var DefaultConfig = Config{
 	AppName: "air",
 	LogFormat: `{"app_name":"{{.app_name}}","time":"{{.time_rfc3339}}","level":"{{.level}}",` +
 		`"file":"{{.short_file}}","line":"{{.line}}"}`,
-	Address:            "localhost:2333",
-	MaxHeaderBytes:     1 << 20,
-	TemplateRoot:       "templates",
-	TemplateExts:       []string{".html"},
-	TemplateLeftDelim:  "{{",
-	TemplateRightDelim: "}}",
-	AssetRoot:          "assets",
-	AssetExts:          []string{".html", ".css", ".js", ".json", ".xml", ".svg"},
+	Address:                  "localhost:2333",
+	UnixSocketMode:           0666,
+	KeepAliveEnabled:         true,
+	UpgradeTimeout:           30 * time.Second,
+	TLSMinVersion:            "1.2",
+	TLSClientAuth:            "none",
+	MaxHeaderBytes:           1 << 20,
+	MethodOverrideMethods:    []string{PUT, PATCH, DELETE},
+	VersionHeader:            "X-API-Version",
+	WebSocketMaxMessageBytes: 1 << 20,
+	TemplateRoot:             "templates",
+	TemplateExts:             []string{".html"},
+	TemplateLeftDelim:        "{{",
+	TemplateRightDelim:       "}}",
+	AssetRoot:                "assets",
+	AssetExts:                []string{".html", ".css", ".js", ".json", ".xml", ".svg"},
 }
 
 // NewConfig returns a pointer of a new instance of the `Config` by parsing the config file found in
@@ -228,15 +548,42 @@ func (c *Config) fillData() {
 	if lf, ok := c.Data["log_format"].(string); ok {
 		c.LogFormat = lf
 	}
+	if saoe, ok := c.Data["startup_abort_on_error"].(bool); ok {
+		c.StartupAbortOnError = saoe
+	}
 	if a, ok := c.Data["address"].(string); ok {
 		c.Address = a
 	}
+	if usm, ok := c.Data["unix_socket_mode"].(int64); ok {
+		c.UnixSocketMode = os.FileMode(usm)
+	}
 	if rt, ok := c.Data["read_timeout"].(int64); ok {
 		c.ReadTimeout = time.Duration(rt) * time.Millisecond
 	}
+	if rht, ok := c.Data["read_header_timeout"].(int64); ok {
+		c.ReadHeaderTimeout = time.Duration(rht) * time.Millisecond
+	}
 	if wt, ok := c.Data["write_timeout"].(int64); ok {
 		c.WriteTimeout = time.Duration(wt) * time.Millisecond
 	}
+	if it, ok := c.Data["idle_timeout"].(int64); ok {
+		c.IdleTimeout = time.Duration(it) * time.Millisecond
+	}
+	if kae, ok := c.Data["keep_alive_enabled"].(bool); ok {
+		c.KeepAliveEnabled = kae
+	}
+	if mc, ok := c.Data["max_connections"].(int64); ok {
+		c.MaxConnections = int(mc)
+	}
+	if gst, ok := c.Data["graceful_shutdown_timeout"].(int64); ok {
+		c.GracefulShutdownTimeout = time.Duration(gst) * time.Millisecond
+	}
+	if ue, ok := c.Data["upgrade_enabled"].(bool); ok {
+		c.UpgradeEnabled = ue
+	}
+	if ut, ok := c.Data["upgrade_timeout"].(int64); ok {
+		c.UpgradeTimeout = time.Duration(ut) * time.Millisecond
+	}
 	if mhb, ok := c.Data["max_header_bytes"].(int64); ok {
 		c.MaxHeaderBytes = int(mhb)
 	}
@@ -246,6 +593,94 @@ func (c *Config) fillData() {
 	if tkf, ok := c.Data["tls_key_file"].(string); ok {
 		c.TLSKeyFile = tkf
 	}
+	if tcs, ok := c.Data["tls_certificates"].([]map[string]interface{}); ok {
+		c.TLSCertificates = make([]TLSCertificateConfig, 0, len(tcs))
+		for _, tc := range tcs {
+			tcc := TLSCertificateConfig{}
+			tcc.CertFile, _ = tc["cert_file"].(string)
+			tcc.KeyFile, _ = tc["key_file"].(string)
+			c.TLSCertificates = append(c.TLSCertificates, tcc)
+		}
+	}
+	if tmv, ok := c.Data["tls_min_version"].(string); ok {
+		c.TLSMinVersion = tmv
+	}
+	if tcss, ok := c.Data["tls_cipher_suites"].([]interface{}); ok {
+		c.TLSCipherSuites = []string{}
+		for _, tcs := range tcss {
+			c.TLSCipherSuites = append(c.TLSCipherSuites, tcs.(string))
+		}
+	}
+	if tccf, ok := c.Data["tls_client_ca_file"].(string); ok {
+		c.TLSClientCAFile = tccf
+	}
+	if tca, ok := c.Data["tls_client_auth"].(string); ok {
+		c.TLSClientAuth = tca
+	}
+	if he, ok := c.Data["h2c_enabled"].(bool); ok {
+		c.H2CEnabled = he
+	}
+	if ppe, ok := c.Data["proxy_protocol_enabled"].(bool); ok {
+		c.ProxyProtocolEnabled = ppe
+	}
+	if pptcs, ok := c.Data["proxy_protocol_trusted_cidrs"].([]interface{}); ok {
+		c.ProxyProtocolTrustedCIDRs = []string{}
+		for _, pptc := range pptcs {
+			c.ProxyProtocolTrustedCIDRs = append(c.ProxyProtocolTrustedCIDRs,
+				pptc.(string))
+		}
+	}
+	if ls, ok := c.Data["listeners"].([]map[string]interface{}); ok {
+		c.Listeners = make([]ListenerConfig, 0, len(ls))
+		for _, l := range ls {
+			lc := ListenerConfig{}
+			lc.Address, _ = l["address"].(string)
+			lc.TLSCertFile, _ = l["tls_cert_file"].(string)
+			lc.TLSKeyFile, _ = l["tls_key_file"].(string)
+			lc.HTTPSRedirect, _ = l["https_redirect"].(bool)
+			c.Listeners = append(c.Listeners, lc)
+		}
+	}
+	if rss, ok := c.Data["router_strict_slash"].(bool); ok {
+		c.RouterStrictSlash = rss
+	}
+	if rci, ok := c.Data["router_case_insensitive"].(bool); ok {
+		c.RouterCaseInsensitive = rci
+	}
+	if rrc, ok := c.Data["router_redirect_code"].(int64); ok {
+		c.RouterRedirectCode = int(rrc)
+	}
+	if moe, ok := c.Data["method_override_enabled"].(bool); ok {
+		c.MethodOverrideEnabled = moe
+	}
+	if moms, ok := c.Data["method_override_methods"].([]interface{}); ok {
+		c.MethodOverrideMethods = []string{}
+		for _, mom := range moms {
+			c.MethodOverrideMethods = append(c.MethodOverrideMethods,
+				strings.ToUpper(mom.(string)))
+		}
+	}
+	if vh, ok := c.Data["version_header"].(string); ok {
+		c.VersionHeader = vh
+	}
+	if wsos, ok := c.Data["websocket_origins"].([]interface{}); ok {
+		c.WebSocketOrigins = []string{}
+		for _, wso := range wsos {
+			c.WebSocketOrigins = append(c.WebSocketOrigins, wso.(string))
+		}
+	}
+	if wsss, ok := c.Data["websocket_subprotocols"].([]interface{}); ok {
+		c.WebSocketSubprotocols = []string{}
+		for _, wss := range wsss {
+			c.WebSocketSubprotocols = append(c.WebSocketSubprotocols, wss.(string))
+		}
+	}
+	if wsce, ok := c.Data["websocket_compression_enabled"].(bool); ok {
+		c.WebSocketCompressionEnabled = wsce
+	}
+	if wsmmb, ok := c.Data["websocket_max_message_bytes"].(int64); ok {
+		c.WebSocketMaxMessageBytes = int(wsmmb)
+	}
 	if tr, ok := c.Data["template_root"].(string); ok {
 		c.TemplateRoot = tr
 	}
@@ -14,12 +14,38 @@ app_name = "air"
 debug_mode = true
 logger_enabled = true
 log_format = "air_log"
+startup_abort_on_error = true
 address = "127.0.0.1:2333"
+unix_socket_mode = 0o660
 read_timeout = 200
+read_header_timeout = 100
 write_timeout = 200
+idle_timeout = 300
+keep_alive_enabled = false
+max_connections = 1000
+graceful_shutdown_timeout = 5000
+upgrade_enabled = true
+upgrade_timeout = 10000
 max_header_bytes = 65536
 tls_cert_file = "path_to_tls_cert_file"
 tls_key_file = "path_to_tls_key_file"
+tls_min_version = "1.3"
+tls_cipher_suites = ["TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"]
+tls_client_ca_file = "path_to_tls_client_ca_file"
+tls_client_auth = "require_and_verify"
+h2c_enabled = true
+proxy_protocol_enabled = true
+proxy_protocol_trusted_cidrs = ["10.0.0.0/8"]
+router_strict_slash = true
+router_case_insensitive = true
+router_redirect_code = 308
+method_override_enabled = true
+method_override_methods = ["put", "DELETE"]
+version_header = "Accept-Version"
+websocket_origins = ["https://example.com"]
+websocket_subprotocols = ["chat"]
+websocket_compression_enabled = true
+websocket_max_message_bytes = 4096
 template_root = "ts"
 template_exts = [".tmpl"]
 template_left_delim = "<<"
@@ -29,6 +55,19 @@ coffer_enabled = true
 asset_root = "as"
 asset_exts = [".jpg"]
 asset_minified = true
+
+[[tls_certificates]]
+cert_file = "path_to_cert_file"
+key_file = "path_to_key_file"
+
+[[listeners]]
+address = ":80"
+https_redirect = true
+
+[[listeners]]
+address = ":443"
+tls_cert_file = "path_to_tls_cert_file"
+tls_key_file = "path_to_tls_key_file"
 `
 
 	f, _ := os.Create("config.toml")
@@ -43,12 +82,53 @@ asset_minified = true
 	assert.Equal(t, true, c.DebugMode)
 	assert.Equal(t, true, c.LoggerEnabled)
 	assert.Equal(t, "air_log", c.LogFormat)
+	assert.Equal(t, true, c.StartupAbortOnError)
 	assert.Equal(t, "127.0.0.1:2333", c.Address)
+	assert.Equal(t, os.FileMode(0660), c.UnixSocketMode)
 	assert.Equal(t, 200*time.Millisecond, c.ReadTimeout)
+	assert.Equal(t, 100*time.Millisecond, c.ReadHeaderTimeout)
 	assert.Equal(t, 200*time.Millisecond, c.WriteTimeout)
+	assert.Equal(t, 300*time.Millisecond, c.IdleTimeout)
+	assert.Equal(t, false, c.KeepAliveEnabled)
+	assert.Equal(t, 1000, c.MaxConnections)
+	assert.Equal(t, 5*time.Second, c.GracefulShutdownTimeout)
+	assert.Equal(t, true, c.UpgradeEnabled)
+	assert.Equal(t, 10*time.Second, c.UpgradeTimeout)
 	assert.Equal(t, 65536, c.MaxHeaderBytes)
 	assert.Equal(t, "path_to_tls_cert_file", c.TLSCertFile)
+	assert.Equal(t, []TLSCertificateConfig{
+		{CertFile: "path_to_cert_file", KeyFile: "path_to_key_file"},
+	}, c.TLSCertificates)
+	assert.Equal(t, "1.3", c.TLSMinVersion)
+	assert.Equal(
+		t,
+		[]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		c.TLSCipherSuites,
+	)
+	assert.Equal(t, "path_to_tls_client_ca_file", c.TLSClientCAFile)
+	assert.Equal(t, "require_and_verify", c.TLSClientAuth)
+	assert.Equal(t, true, c.H2CEnabled)
+	assert.Equal(t, true, c.ProxyProtocolEnabled)
+	assert.Equal(t, []string{"10.0.0.0/8"}, c.ProxyProtocolTrustedCIDRs)
+	assert.Equal(t, []ListenerConfig{
+		{Address: ":80", HTTPSRedirect: true},
+		{
+			Address:     ":443",
+			TLSCertFile: "path_to_tls_cert_file",
+			TLSKeyFile:  "path_to_tls_key_file",
+		},
+	}, c.Listeners)
 	assert.Equal(t, "path_to_tls_key_file", c.TLSKeyFile)
+	assert.Equal(t, true, c.RouterStrictSlash)
+	assert.Equal(t, true, c.RouterCaseInsensitive)
+	assert.Equal(t, 308, c.RouterRedirectCode)
+	assert.Equal(t, true, c.MethodOverrideEnabled)
+	assert.Equal(t, []string{PUT, DELETE}, c.MethodOverrideMethods)
+	assert.Equal(t, "Accept-Version", c.VersionHeader)
+	assert.Equal(t, []string{"https://example.com"}, c.WebSocketOrigins)
+	assert.Equal(t, []string{"chat"}, c.WebSocketSubprotocols)
+	assert.Equal(t, true, c.WebSocketCompressionEnabled)
+	assert.Equal(t, 4096, c.WebSocketMaxMessageBytes)
 	assert.Equal(t, "ts", c.TemplateRoot)
 	assert.Equal(t, []string{".tmpl"}, c.TemplateExts)
 	assert.Equal(t, "<<", c.TemplateLeftDelim)
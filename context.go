@@ -2,6 +2,7 @@ package air
 
 import (
 	"context"
+	"crypto/x509"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -25,6 +26,12 @@ type Context struct {
 	ParamValues  []string
 	Handler      Handler
 
+	allowedMethods  []string
+	hostParamNames  []string
+	hostParamValues []string
+	routeState      routeState
+	version         string
+
 	// Cancel is non-nil if one of the `SetCancel()`, the `SetDeadline()` or the `SetTimeout()`
 	// is called. It will be called when the HTTP server finishes the current cycle if it is
 	// non-nil and has never been called.
@@ -67,16 +74,93 @@ func (c *Context) SetValue(key interface{}, val interface{}) {
 	c.Context = context.WithValue(c.Context, key, val)
 }
 
-// Param returns the path param value by the name.
+// Param returns the path param value by the name. It also returns the host param value of the
+// matched `Air#Host()` by the name if there is no such path param.
 func (c *Context) Param(name string) string {
 	for i, n := range c.ParamNames {
 		if n == name {
 			return c.ParamValues[i]
 		}
 	}
+	for i, n := range c.hostParamNames {
+		if n == name {
+			return c.hostParamValues[i]
+		}
+	}
 	return ""
 }
 
+// AllowedMethods returns the HTTP methods that are allowed by the route matched for the current
+// HTTP request. It returns nil if there is no route matched.
+func (c *Context) AllowedMethods() []string {
+	return c.allowedMethods
+}
+
+// Version returns the API version of the route matched for the current HTTP request. It returns ""
+// if the route is not registered by the `Air#Version()`.
+func (c *Context) Version() string {
+	return c.version
+}
+
+// PeerCertificates returns the verified certificate chain of the TLS client of the current HTTP
+// request, starting with the leaf certificate. It returns nil if the TLS client certificate is not
+// presented or not verified by the `Config#TLSClientCAFile`.
+func (c *Context) PeerCertificates() []*x509.Certificate {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	return c.Request.TLS.VerifiedChains[0]
+}
+
+// PeerIdentities returns the identities of the verified TLS client of the current HTTP request,
+// which are the common name of the subject, and the DNS names, the email addresses, the IP
+// addresses and the URIs of the subject alternative names of the leaf certificate. It returns nil
+// if the `PeerCertificates()` returns nil.
+func (c *Context) PeerIdentities() []string {
+	pcs := c.PeerCertificates()
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	leaf := pcs[0]
+
+	ids := []string{}
+	if leaf.Subject.CommonName != "" {
+		ids = append(ids, leaf.Subject.CommonName)
+	}
+
+	ids = append(ids, leaf.DNSNames...)
+	ids = append(ids, leaf.EmailAddresses...)
+	for _, ip := range leaf.IPAddresses {
+		ids = append(ids, ip.String())
+	}
+
+	for _, u := range leaf.URIs {
+		ids = append(ids, u.String())
+	}
+
+	return ids
+}
+
+// UpgradeWebSocket upgrades the HTTP connection of the current HTTP request to a WebSocket
+// connection after checking the WebSocket handshake with the `Config#WebSocketOrigins`, the
+// `Config#WebSocketSubprotocols` and the `Config#WebSocketCompressionEnabled`. The headers of the
+// `Response` will be sent with the handshake response. The `WebSocketConn` should be closed by the
+// caller.
+func (c *Context) UpgradeWebSocket() (*WebSocketConn, error) {
+	return upgradeWebSocket(c)
+}
+
+// AbsoluteURLFor returns an absolute URL generated from the route named by the name with the params
+// and the optional query. The scheme and the host are taken from the current HTTP request.
+func (c *Context) AbsoluteURLFor(name string, params Map, query url.Values) (string, error) {
+	u, err := c.Air.URLFor(name, params, query)
+	if err != nil {
+		return "", err
+	}
+	return c.Request.Scheme() + "://" + c.Request.Host + u, nil
+}
+
 // feed feeds the req and the rw into where they should be.
 func (c *Context) feed(req *http.Request, rw http.ResponseWriter) {
 	c.Context = req.Context()
@@ -93,6 +177,12 @@ func (c *Context) reset() {
 	c.ParamNames = c.ParamNames[:0]
 	c.ParamValues = c.ParamValues[:0]
 	c.Handler = NotFoundHandler
+	c.allowedMethods = nil
+	c.hostParamNames = nil
+	c.hostParamValues = c.hostParamValues[:0]
+	c.routeState.router = nil
+	c.routeState.points = c.routeState.points[:0]
+	c.version = ""
 	c.Data = c.Response.Data
 }
 
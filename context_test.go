@@ -2,6 +2,12 @@ package air
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -44,3 +50,38 @@ func TestContextSetValue(t *testing.T) {
 	assert.Equal(t, "Air", c.Value("name").(string))
 	assert.Equal(t, "Aofei Sheng", c.Value("author").(string))
 }
+
+func TestContextPeerIdentities(t *testing.T) {
+	a := New()
+	c := a.contextPool.Get().(*Context)
+
+	req := httptest.NewRequest(GET, "/", nil)
+	c.feed(req, httptest.NewRecorder())
+	assert.Nil(t, c.PeerCertificates())
+	assert.Nil(t, c.PeerIdentities())
+
+	u, _ := url.Parse("spiffe://example.com/foo")
+	leaf := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "foo"},
+		DNSNames:       []string{"foo.example.com"},
+		EmailAddresses: []string{"foo@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("127.0.0.1")},
+		URIs:           []*url.URL{u},
+	}
+	root := &x509.Certificate{Subject: pkix.Name{CommonName: "root"}}
+
+	req.TLS = &tls.ConnectionState{}
+	c.feed(req, httptest.NewRecorder())
+	assert.Nil(t, c.PeerIdentities())
+
+	req.TLS.VerifiedChains = [][]*x509.Certificate{{leaf, root}}
+	c.feed(req, httptest.NewRecorder())
+	assert.Equal(t, []*x509.Certificate{leaf, root}, c.PeerCertificates())
+	assert.Equal(t, []string{
+		"foo",
+		"foo.example.com",
+		"foo@example.com",
+		"127.0.0.1",
+		"spiffe://example.com/foo",
+	}, c.PeerIdentities())
+}
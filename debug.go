@@ -0,0 +1,162 @@
+package air
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+type (
+	// routeInfos is used to sort the `RouteInfo`s by the host, the API version, the path and
+	// the method.
+	routeInfos []RouteInfo
+
+	// nodeInfo is the public information of a `node`. It's used by the `RouterDebugHandler`.
+	nodeInfo struct {
+		Kind       string      `json:"kind"`
+		Prefix     string      `json:"prefix"`
+		Path       string      `json:"path,omitempty"`
+		Constraint string      `json:"constraint,omitempty"`
+		Methods    []string    `json:"methods,omitempty"`
+		Children   []*nodeInfo `json:"children,omitempty"`
+	}
+)
+
+// RouterDebugHandler renders the route table and the radix trees of the routers of the current
+// `Air` instance, including the ones of its hosts and API versions. It responds in the JSON if the "format" query value is "json" or the "Accept" header
+// contains the "application/json", otherwise it responds in the plain text.
+//
+// It's not registered by default, register it when needed:
+//
+//	a.GET("/debug/router", air.RouterDebugHandler)
+func RouterDebugHandler(c *Context) error {
+	routes := c.Air.Routes()
+	tree := newNodeInfo(c.Air.router.tree)
+	hosts := make(map[string]*nodeInfo, len(c.Air.hosts))
+	for _, h := range c.Air.hosts {
+		hosts[h.pattern] = newNodeInfo(h.router.tree)
+	}
+
+	versions := make(map[string]*nodeInfo, len(c.Air.versions))
+	for _, v := range c.Air.versions {
+		versions[v.name] = newNodeInfo(v.router.tree)
+	}
+
+	if c.QueryValue("format") == "json" ||
+		strings.Contains(c.Request.Header.Get(HeaderAccept), MIMEApplicationJSON) {
+		return c.JSON(Map{
+			"routes":   routes,
+			"tree":     tree,
+			"hosts":    hosts,
+			"versions": versions,
+		})
+	}
+
+	buf := &bytes.Buffer{}
+
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tHOST\tVERSION\tPATH\tNAME\tHANDLER\tGASES\tGROUP")
+	for _, r := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", r.Method, r.Host, r.Version,
+			r.Path, r.Name, r.HandlerName, r.GasCount, r.GroupPrefix)
+	}
+	tw.Flush()
+
+	buf.WriteByte('\n')
+	tree.write(buf, 0)
+
+	for _, h := range c.Air.hosts {
+		fmt.Fprintf(buf, "\nHost %s:\n", h.pattern)
+		hosts[h.pattern].write(buf, 0)
+	}
+
+	for _, v := range c.Air.versions {
+		fmt.Fprintf(buf, "\nVersion %s:\n", v.name)
+		versions[v.name].write(buf, 0)
+	}
+
+	return c.String(buf.String())
+}
+
+// Len implements the `sort.Interface#Len()`.
+func (ris routeInfos) Len() int {
+	return len(ris)
+}
+
+// Less implements the `sort.Interface#Less()`.
+func (ris routeInfos) Less(i, j int) bool {
+	if ris[i].Host != ris[j].Host {
+		return ris[i].Host < ris[j].Host
+	} else if ris[i].Version != ris[j].Version {
+		return compareVersions(ris[i].Version, ris[j].Version) < 0
+	} else if ris[i].Path != ris[j].Path {
+		return ris[i].Path < ris[j].Path
+	}
+	return methodIndex(ris[i].Method) < methodIndex(ris[j].Method)
+}
+
+// Swap implements the `sort.Interface#Swap()`.
+func (ris routeInfos) Swap(i, j int) {
+	ris[i], ris[j] = ris[j], ris[i]
+}
+
+// newNodeInfo returns a pointer of a new instance of the `nodeInfo` from the n recursively.
+func newNodeInfo(n *node) *nodeInfo {
+	ni := &nodeInfo{
+		Prefix: n.prefix,
+		Path:   n.pristinePath,
+	}
+
+	switch n.kind {
+	case staticKind:
+		ni.Kind = "static"
+	case paramKind:
+		ni.Kind = "param"
+	case anyKind:
+		ni.Kind = "any"
+	}
+
+	if n.constraint != nil {
+		ni.Constraint = n.constraint.pattern
+	}
+
+	for _, m := range methods {
+		if m == HEAD && n.methodHandler.head == nil {
+			continue
+		} else if n.handler(m) != nil {
+			ni.Methods = append(ni.Methods, m)
+		}
+	}
+
+	for _, c := range n.children {
+		ni.Children = append(ni.Children, newNodeInfo(c))
+	}
+
+	return ni
+}
+
+// write writes the ni and its children into the buf as an indented text tree.
+func (ni *nodeInfo) write(buf *bytes.Buffer, depth int) {
+	buf.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(buf, "%q %s", ni.Prefix, ni.Kind)
+	if len(ni.Methods) > 0 {
+		fmt.Fprintf(buf, " [%s] %s", strings.Join(ni.Methods, ", "), ni.Path)
+	}
+	buf.WriteByte('\n')
+
+	for _, c := range ni.Children {
+		c.write(buf, depth+1)
+	}
+}
+
+// methodIndex returns the index of the method in the `methods`. The unknown methods are always
+// behind the known ones.
+func methodIndex(method string) int {
+	for i, m := range methods {
+		if m == method {
+			return i
+		}
+	}
+	return len(methods)
+}
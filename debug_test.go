@@ -0,0 +1,52 @@
+package air
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterDebugHandler(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+	h := func(*Context) error { return nil }
+
+	a.GET("/users/:id<int>", h).Name("user.show")
+	a.GET("/debug/router", RouterDebugHandler)
+	a.Version("2").GET("/posts/:slug", h)
+
+	req, _ := http.NewRequest(GET, "/debug/router", nil)
+	rec := httptest.NewRecorder()
+
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "user.show")
+	assert.Contains(t, rec.Body.String(), `":<int>" param [GET] /users/:id<int>`)
+	assert.Contains(t, rec.Body.String(), "Version 2:")
+	assert.Contains(t, rec.Body.String(), `":" param [GET] /posts/:slug`)
+
+	req, _ = http.NewRequest(GET, "/debug/router?format=json", nil)
+	rec = httptest.NewRecorder()
+
+	a.server.ServeHTTP(rec, req)
+
+	var data struct {
+		Routes   []RouteInfo          `json:"routes"`
+		Tree     *nodeInfo            `json:"tree"`
+		Versions map[string]*nodeInfo `json:"versions"`
+	}
+
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &data))
+	assert.Len(t, data.Routes, 3)
+	assert.Equal(t, "/", data.Tree.Prefix)
+	assert.Equal(t, "static", data.Tree.Kind)
+	assert.Equal(t, "/posts/", data.Versions["2"].Prefix)
+}
+
+func TestRouteInfosSort(t *testing.T) {
+	assert.Equal(t, 0, methodIndex(GET))
+	assert.Equal(t, len(methods), methodIndex("PROPFIND"))
+}
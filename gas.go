@@ -0,0 +1,65 @@
+package air
+
+import "strings"
+
+// MethodOverrideGas is a gas that overrides the method of the POST requests by the
+// "X-HTTP-Method-Override" header or the "_method" field of the URL-encoded form body. Only the
+// methods in the `Config#MethodOverrideMethods` are allowed to be the targets.
+//
+// It will be performed before all the pregases if the `Config#MethodOverrideEnabled` is true,
+// so it usually does not need to be contained manually.
+func MethodOverrideGas(next Handler) Handler {
+	return func(c *Context) error {
+		if c.Request.Method != POST {
+			return next(c)
+		}
+
+		// Only the URL-encoded bodies are parsed for the "_method", so
+		// the multipart bodies are never buffered before routing.
+		m := c.Request.Header.Get(HeaderXHTTPMethodOverride)
+		if m == "" && strings.HasPrefix(
+			c.Request.Header.Get(HeaderContentType),
+			MIMEApplicationXWWWFormURLEncoded,
+		) {
+			m = c.Request.PostFormValue("_method")
+		}
+
+		m = strings.ToUpper(m)
+		for _, om := range c.Air.Config.MethodOverrideMethods {
+			if m == om {
+				c.Request.Method = m
+				break
+			}
+		}
+
+		return next(c)
+	}
+}
+
+// ClientIdentityGas returns a gas that only allows the HTTP requests from the verified TLS clients
+// that have any of the identities returned by the `Context#PeerIdentities()`. It returns the
+// `ErrUnauthorized` if the TLS client is not verified, or the `ErrForbidden` if none of the
+// identities matches.
+//
+// It works only with the listeners that have the TLS settings, and the `Config#TLSClientAuth`
+// should be "verify_if_given" or "require_and_verify".
+func ClientIdentityGas(identities ...string) Gas {
+	return func(next Handler) Handler {
+		return func(c *Context) error {
+			pis := c.PeerIdentities()
+			if pis == nil {
+				return ErrUnauthorized
+			}
+
+			for _, pi := range pis {
+				for _, id := range identities {
+					if pi == id {
+						return next(c)
+					}
+				}
+			}
+
+			return ErrForbidden
+		}
+	}
+}
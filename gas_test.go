@@ -0,0 +1,117 @@
+package air
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethodOverrideGas(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+	a.Config.MethodOverrideEnabled = true
+
+	h := func(c *Context) error { return c.String(c.Request.Method) }
+	a.POST("/", h)
+	a.PUT("/", h)
+	a.DELETE("/", h)
+	a.Precontain(WrapGas(func(c *Context) error {
+		c.Response.Header().Set("X-Method", c.Request.Method)
+		return nil
+	}))
+
+	req, _ := http.NewRequest(POST, "/", nil)
+	req.Header.Set(HeaderXHTTPMethodOverride, "put")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, PUT, rec.Body.String())
+	assert.Equal(t, PUT, rec.Header().Get("X-Method"))
+
+	form := url.Values{"_method": {DELETE}}
+	req, _ = http.NewRequest(POST, "/", strings.NewReader(form.Encode()))
+	req.Header.Set(HeaderContentType, MIMEApplicationXWWWFormURLEncoded)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, DELETE, rec.Body.String())
+
+	req, _ = http.NewRequest(POST, "/?_method=DELETE", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, POST, rec.Body.String())
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("_method", DELETE)
+	mw.Close()
+	req, _ = http.NewRequest(POST, "/", body)
+	req.Header.Set(HeaderContentType, mw.FormDataContentType())
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, POST, rec.Body.String())
+	assert.Nil(t, req.MultipartForm)
+
+	req, _ = http.NewRequest(POST, "/", nil)
+	req.Header.Set(HeaderXHTTPMethodOverride, CONNECT)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, POST, rec.Body.String())
+
+	req, _ = http.NewRequest(GET, "/", nil)
+	req.Header.Set(HeaderXHTTPMethodOverride, PUT)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	a.Config.MethodOverrideEnabled = false
+
+	req, _ = http.NewRequest(POST, "/", nil)
+	req.Header.Set(HeaderXHTTPMethodOverride, PUT)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, POST, rec.Body.String())
+}
+
+func TestClientIdentityGas(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+
+	a.GET("/", func(c *Context) error {
+		return c.String("allowed")
+	}, ClientIdentityGas("foo", "bar.example.com"))
+
+	cases := []struct {
+		cn       string
+		dnsNames []string
+		code     int
+	}{
+		{"", nil, http.StatusUnauthorized},
+		{"foo", nil, http.StatusOK},
+		{"baz", []string{"bar.example.com"}, http.StatusOK},
+		{"baz", []string{"baz.example.com"}, http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(GET, "/", nil)
+		if c.cn != "" {
+			req.TLS = &tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{{
+					Subject:  pkix.Name{CommonName: c.cn},
+					DNSNames: c.dnsNames,
+				}}},
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, c.code, rec.Code, c.cn)
+	}
+}
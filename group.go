@@ -1,31 +1,75 @@
 package air
 
-import "path"
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
 
 // Group is a set of sub-routes for a specified route. It can be used for inner routes that share a
 // common gas or functionality that should be separate from the parent `Air` instance while still
 // inheriting from it.
 type Group struct {
-	air *Air
+	air    *Air
+	router *router
 
-	prefix string
-	gases  []Gas
+	prefix      string
+	constraints []*paramConstraint
+	gases       []Gas
+
+	notFound             Handler
+	notFoundOnce         sync.Once
+	methodNotAllowed     Handler
+	methodNotAllowedOnce sync.Once
+
+	// NotFoundHandler handles the HTTP requests that have no route matched and whose paths
+	// start with the prefix of the `Group`. It will be wrapped by the group-level gases once
+	// it's first used. The one of the `Group` that has the longest prefix wins. The
+	// `Air#NotFoundHandler` will be used if there is no `Group` has it.
+	NotFoundHandler Handler
+
+	// MethodNotAllowedHandler is like the `NotFoundHandler`, but handles the HTTP requests
+	// whose methods are not allowed by the matched routes.
+	MethodNotAllowedHandler Handler
 }
 
 // NewGroup returns a pointer of a new router group with the prefix and the optional group-level
 // gases.
 func NewGroup(a *Air, prefix string, gases ...Gas) *Group {
-	return &Group{
-		air:    a,
-		prefix: prefix,
-		gases:  gases,
-	}
+	return newGroup(a, a.router, prefix, gases)
 }
 
 // NewSubGroup creates a pointer of a new sub-group with the prefix and the optional sub-group-level
 // gases.
 func (g *Group) NewSubGroup(prefix string, gases ...Gas) *Group {
-	return NewGroup(g.air, g.prefix+prefix, append(g.gases, gases...)...)
+	return newGroup(g.air, g.router, g.prefix+prefix, append(g.gases, gases...))
+}
+
+// newGroup returns a pointer of a new router group registered in the r with the prefix and the
+// gases.
+func newGroup(a *Air, r *router, prefix string, gases []Gas) *Group {
+	g := &Group{
+		air:    a,
+		router: r,
+		prefix: prefix,
+		gases:  gases,
+	}
+
+	for _, s := range strings.Split(prefix, "/") {
+		if strings.HasPrefix(s, ":") {
+			var pc *paramConstraint
+			if _, pattern, _ := splitParam(s[1:]); pattern != "" {
+				pc = r.paramConstraint(pattern)
+			}
+
+			g.constraints = append(g.constraints, pc)
+		}
+	}
+
+	r.groups = append(r.groups, g)
+
+	return g
 }
 
 // Contain implements the `Air#Contain()`.
@@ -34,43 +78,163 @@ func (g *Group) Contain(gases ...Gas) {
 }
 
 // GET implements the `Air#GET()`.
-func (g *Group) GET(path string, h Handler, gases ...Gas) {
-	g.add(GET, path, h, gases...)
+func (g *Group) GET(path string, h Handler, gases ...Gas) *Route {
+	return g.add(GET, path, h, gases...)
+}
+
+// HEAD implements the `Air#HEAD()`.
+func (g *Group) HEAD(path string, h Handler, gases ...Gas) *Route {
+	return g.add(HEAD, path, h, gases...)
 }
 
 // POST implements the `Air#POST()`.
-func (g *Group) POST(path string, h Handler, gases ...Gas) {
-	g.add(POST, path, h, gases...)
+func (g *Group) POST(path string, h Handler, gases ...Gas) *Route {
+	return g.add(POST, path, h, gases...)
 }
 
 // PUT implements the `Air#PUT()`.
-func (g *Group) PUT(path string, h Handler, gases ...Gas) {
-	g.add(PUT, path, h, gases...)
+func (g *Group) PUT(path string, h Handler, gases ...Gas) *Route {
+	return g.add(PUT, path, h, gases...)
+}
+
+// PATCH implements the `Air#PATCH()`.
+func (g *Group) PATCH(path string, h Handler, gases ...Gas) *Route {
+	return g.add(PATCH, path, h, gases...)
 }
 
 // DELETE implements the `Air#DELETE()`.
-func (g *Group) DELETE(path string, h Handler, gases ...Gas) {
-	g.add(DELETE, path, h, gases...)
+func (g *Group) DELETE(path string, h Handler, gases ...Gas) *Route {
+	return g.add(DELETE, path, h, gases...)
+}
+
+// CONNECT implements the `Air#CONNECT()`.
+func (g *Group) CONNECT(path string, h Handler, gases ...Gas) *Route {
+	return g.add(CONNECT, path, h, gases...)
+}
+
+// OPTIONS implements the `Air#OPTIONS()`.
+func (g *Group) OPTIONS(path string, h Handler, gases ...Gas) *Route {
+	return g.add(OPTIONS, path, h, gases...)
+}
+
+// TRACE implements the `Air#TRACE()`.
+func (g *Group) TRACE(path string, h Handler, gases ...Gas) *Route {
+	return g.add(TRACE, path, h, gases...)
+}
+
+// Any implements the `Air#Any()`.
+func (g *Group) Any(path string, h Handler, gases ...Gas) []*Route {
+	return g.Match(methods[:], path, h, gases...)
+}
+
+// Match implements the `Air#Match()`.
+func (g *Group) Match(methods []string, path string, h Handler, gases ...Gas) []*Route {
+	rs := make([]*Route, 0, len(methods))
+	for _, m := range methods {
+		rs = append(rs, g.add(m, path, h, gases...))
+	}
+	return rs
 }
 
 // Static implements the `Air#Static()`.
-func (g *Group) Static(prefix, root string) {
-	g.GET(prefix+"*", func(c *Context) error {
+func (g *Group) Static(prefix, root string) *Route {
+	return g.GET(prefix+"*", func(c *Context) error {
 		return c.File(path.Join(root, c.Param("*")))
 	})
 }
 
 // File implements the `Air#File()`.
-func (g *Group) File(path, file string) {
-	g.GET(path, func(c *Context) error {
+func (g *Group) File(path, file string) *Route {
+	return g.GET(path, func(c *Context) error {
 		return c.File(file)
 	})
 }
 
+// WebSocket implements the `Air#WebSocket()`.
+func (g *Group) WebSocket(path string, h WebSocketHandler, gases ...Gas) *Route {
+	return g.GET(path, webSocketHandler(h), gases...)
+}
+
+// Mount implements the `Air#Mount()`.
+func (g *Group) Mount(prefix string, h http.Handler, gases ...Gas) []*Route {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mh := mountHandler(g.prefix+prefix, h)
+	rs := g.Any(prefix+"/*", mh, gases...)
+	if g.prefix+prefix != "" {
+		rs = append(rs, g.Any(prefix, mh, gases...)...)
+	}
+	return rs
+}
+
+// matchPrefix reports whether the path starts with the prefix of the g. The params in the prefix
+// match any non-empty path segment that satisfies their constraints.
+func (g *Group) matchPrefix(path string) bool {
+	prefix, pc := g.prefix, 0 // pc is the index of the current param in the prefix
+	for prefix != "" {
+		if path == "" || path[0] != '/' {
+			return false
+		}
+
+		prefix, path = prefix[1:], path[1:]
+
+		pl := strings.IndexByte(prefix, '/')
+		if pl < 0 {
+			pl = len(prefix)
+		}
+
+		sl := strings.IndexByte(path, '/')
+		if sl < 0 {
+			sl = len(path)
+		}
+
+		if ps, s := prefix[:pl], path[:sl]; ps != "" && ps[0] == ':' {
+			if s == "" || g.constraints[pc] != nil &&
+				!g.constraints[pc].match(unescape(s)) {
+				return false
+			}
+
+			pc++
+		} else if ps != "" && ps != s {
+			return false
+		}
+
+		prefix, path = prefix[pl:], path[sl:]
+	}
+
+	return true
+}
+
+// fallbackHandler returns the `NotFoundHandler`, or the `MethodNotAllowedHandler` if the
+// methodNotAllowed is true, of the g wrapped by the gases of the g. They're composed only once.
+func (g *Group) fallbackHandler(methodNotAllowed bool) Handler {
+	if methodNotAllowed {
+		g.methodNotAllowedOnce.Do(func() {
+			g.methodNotAllowed = g.wrap(g.MethodNotAllowedHandler)
+		})
+		return g.methodNotAllowed
+	}
+
+	g.notFoundOnce.Do(func() {
+		g.notFound = g.wrap(g.NotFoundHandler)
+	})
+
+	return g.notFound
+}
+
+// wrap returns the h wrapped by the gases of the g.
+func (g *Group) wrap(h Handler) Handler {
+	for i := len(g.gases) - 1; i >= 0; i-- {
+		h = g.gases[i](h)
+	}
+	return h
+}
+
 // add implements the `Air#add()`.
-func (g *Group) add(method, path string, h Handler, gases ...Gas) {
-	if path == "/" {
+func (g *Group) add(method, path string, h Handler, gases ...Gas) *Route {
+	if path == "/" && g.prefix != "" {
 		path = ""
 	}
-	g.air.add(method, g.prefix+path, h, append(g.gases, gases...)...)
+	r := g.router.register(method, g.prefix+path, h, append(g.gases, gases...))
+	r.groupPrefix = g.prefix
+	return r
 }
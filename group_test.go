@@ -33,9 +33,16 @@ func TestGroupRESTfulMethods(t *testing.T) {
 	h := func(*Context) error { return nil }
 
 	g.GET("/", h)
+	g.HEAD("/", h)
 	g.POST("/", h)
 	g.PUT("/", h)
+	g.PATCH("/", h)
 	g.DELETE("/", h)
+	g.CONNECT("/", h)
+	g.OPTIONS("/", h)
+	g.TRACE("/", h)
+	g.Any("/any", h)
+	g.Match([]string{GET, POST}, "/match", h)
 }
 
 func TestGroupStatic(t *testing.T) {
@@ -86,3 +93,75 @@ func TestGroupFile(t *testing.T) {
 	a.server.ServeHTTP(rec, req)
 	assert.Equal(t, b, rec.Body.Bytes())
 }
+
+func TestGroupMount(t *testing.T) {
+	a := New()
+	g := NewGroup(a, "/admin")
+
+	g.Mount("/ui", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.URL.Path))
+	}))
+
+	req, _ := http.NewRequest(GET, "/admin/ui/settings", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	assert.Equal(t, "/settings", rec.Body.String())
+
+	req, _ = http.NewRequest(GET, "/admin/ui", nil)
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	assert.Equal(t, "/", rec.Body.String())
+}
+
+func TestGroupNotFoundAndMethodNotAllowedHandlers(t *testing.T) {
+	a := New()
+	a.NotFoundHandler = func(c *Context) error { return c.String("air not found") }
+
+	api := NewGroup(a, "/api", WrapGas(func(c *Context) error {
+		c.Response.Header().Set("X-Group", "api")
+		return nil
+	}))
+	api.NotFoundHandler = func(c *Context) error { return c.JSON(Map{"error": "not found"}) }
+	api.MethodNotAllowedHandler = func(c *Context) error {
+		return c.JSON(Map{"error": "method not allowed"})
+	}
+	api.GET("/users", func(c *Context) error { return c.String("users") })
+
+	v1 := api.NewSubGroup("/:version")
+	v1.NotFoundHandler = func(c *Context) error { return c.String("v not found") }
+
+	composed := 0
+	users := NewGroup(a, "/users/:id<int>", func(next Handler) Handler {
+		composed++
+		return next
+	})
+	users.NotFoundHandler = func(c *Context) error { return c.String("user not found") }
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		group  string
+	}{
+		{GET, "/foo", "air not found", ""},
+		{GET, "/api", `{"error":"not found"}`, "api"},
+		{GET, "/apis/foo", "air not found", ""},
+		{POST, "/api/users", `{"error":"method not allowed"}`, "api"},
+		{"PROPFIND", "/api/users", `{"error":"method not allowed"}`, "api"},
+		{GET, "/api/v1/foo", "v not found", "api"},
+		{GET, "/users/1/foo", "user not found", ""},
+		{GET, "/users/2/foo", "user not found", ""},
+		{GET, "/users/abc/foo", "air not found", ""},
+		{GET, "/users", "air not found", ""},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.target, nil)
+		rec := httptest.NewRecorder()
+		a.ServeHTTP(rec, req)
+		assert.Equal(t, c.body, rec.Body.String(), c.target)
+		assert.Equal(t, c.group, rec.Header().Get("X-Group"), c.target)
+	}
+
+	assert.Equal(t, 1, composed)
+}
@@ -0,0 +1,130 @@
+package air
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// host is a virtual host that has its own router.
+type host struct {
+	pattern    string
+	labels     []string
+	paramNames []string
+	router     *router
+}
+
+// Host returns a pointer of a `Group` that registers the routes into the router of the virtual
+// host matched by the pattern with the optional group-level gases.
+//
+// The pattern is a hostname without the port, such as "api.example.com". The labels of the
+// pattern that start with ":" are params, such as "tenant" in the ":tenant.example.com". The
+// values of them are available through the `Context#Param()`. The exact hosts always win over
+// the hosts with params.
+//
+// The HTTP requests for the unregistered hosts will be handled by the `UnknownHostHandler` if it
+// is non-nil, otherwise they will be routed by the routes registered in the a itself.
+func (a *Air) Host(pattern string, gases ...Gas) *Group {
+	pattern = strings.ToLower(pattern)
+
+	var h *host
+	for _, eh := range a.hosts {
+		if eh.pattern == pattern {
+			h = eh
+			break
+		}
+	}
+
+	if h == nil {
+		h = newHost(a, pattern)
+		a.hosts = append(a.hosts, h)
+	}
+
+	return newGroup(a, h.router, "", gases)
+}
+
+// newHost returns a pointer of a new instance of the `host` with the pattern.
+func newHost(a *Air, pattern string) *host {
+	if pattern == "" {
+		panic("the host pattern cannot be empty")
+	}
+
+	h := &host{
+		pattern: pattern,
+		labels:  strings.Split(pattern, "."),
+	}
+
+	for _, l := range h.labels {
+		if l == "" || l == ":" {
+			panic(fmt.Sprintf("the host pattern %q is invalid", pattern))
+		} else if l[0] == ':' {
+			h.paramNames = append(h.paramNames, l[1:])
+		}
+	}
+
+	h.router = newRouter(a)
+	h.router.host = h
+
+	return h
+}
+
+// match reports whether the hostname matches the h. The values of the params will be appended to
+// the pvs and returned when it matches.
+func (h *host) match(hostname string, pvs []string) ([]string, bool) {
+	labels := strings.Split(hostname, ".")
+	if len(labels) != len(h.labels) {
+		return pvs, false
+	}
+
+	n := len(pvs)
+	for i, l := range h.labels {
+		if l[0] == ':' {
+			if labels[i] == "" {
+				return pvs[:n], false
+			}
+			pvs = append(pvs, labels[i])
+		} else if l != labels[i] {
+			return pvs[:n], false
+		}
+	}
+
+	return pvs, true
+}
+
+// matchHost returns the `host` matching the HTTP request of the c. The values of the host params
+// will be stored into the c. It returns nil if there is no `host` matched.
+func (a *Air) matchHost(c *Context) *host {
+	if len(a.hosts) == 0 {
+		return nil
+	}
+
+	hostname := c.Request.Host
+	if h, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = h
+	}
+
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+	for _, h := range a.hosts {
+		if len(h.paramNames) == 0 && h.pattern == hostname {
+			return h
+		}
+	}
+
+	for _, h := range a.hosts {
+		if len(h.paramNames) == 0 {
+			continue
+		}
+
+		var ok bool
+		if c.hostParamValues, ok = h.match(
+			hostname,
+			c.hostParamValues[:0],
+		); ok {
+			c.hostParamNames = h.paramNames
+			return h
+		}
+	}
+
+	return nil
+}
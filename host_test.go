@@ -0,0 +1,93 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirHost(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+
+	a.GET("/", func(c *Context) error { return c.String("default") })
+
+	api := a.Host("api.example.com")
+	api.GET("/users/:id", func(c *Context) error {
+		return c.String("api " + c.Param("id"))
+	})
+
+	tenant := a.Host(":tenant.example.com")
+	tenant.GET("/", func(c *Context) error {
+		return c.String("tenant " + c.Param("tenant"))
+	})
+
+	assert.Equal(t, api.router, a.Host("API.example.com").router)
+
+	cases := []struct {
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"api.example.com", "/users/1", http.StatusOK, "api 1"},
+		{"API.example.com:8080", "/users/2", http.StatusOK, "api 2"},
+		{"foo.example.com", "/", http.StatusOK, "tenant foo"},
+		{"api.example.com", "/", http.StatusNotFound, ""},
+		{"foo.bar.example.com", "/", http.StatusOK, "default"},
+		{"example.org", "/", http.StatusOK, "default"},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(GET, c.path, nil)
+		req.Host = c.host
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, c.code, rec.Code, c.host)
+		if c.body != "" {
+			assert.Equal(t, c.body, rec.Body.String(), c.host)
+		}
+	}
+
+	a.UnknownHostHandler = func(c *Context) error {
+		return c.String("unknown " + c.Request.Host)
+	}
+
+	req, _ := http.NewRequest(GET, "/", nil)
+	req.Host = "example.org"
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, "unknown example.org", rec.Body.String())
+
+	ris := a.Routes()
+	assert.Len(t, ris, 3)
+	assert.Equal(t, "", ris[0].Host)
+	assert.Equal(t, ":tenant.example.com", ris[1].Host)
+	assert.Equal(t, "api.example.com", ris[2].Host)
+}
+
+func TestAirHostPanics(t *testing.T) {
+	a := New()
+	assert.Panics(t, func() { a.Host("") })
+	assert.Panics(t, func() { a.Host("example..com") })
+	assert.Panics(t, func() { a.Host(":.example.com") })
+}
+
+func TestHostMatch(t *testing.T) {
+	h := newHost(New(), ":tenant.:region.example.com")
+	assert.Equal(t, []string{"tenant", "region"}, h.paramNames)
+
+	pvs, ok := h.match("foo.eu.example.com", nil)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"foo", "eu"}, pvs)
+
+	pvs, ok = h.match("foo.example.com", nil)
+	assert.False(t, ok)
+	assert.Empty(t, pvs)
+
+	pvs, ok = h.match("foo.eu.example.org", nil)
+	assert.False(t, ok)
+	assert.Empty(t, pvs)
+}
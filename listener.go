@@ -0,0 +1,296 @@
+package air
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// unixAddressPrefix is the prefix of the addresses that represent the Unix domain sockets.
+const unixAddressPrefix = "unix:"
+
+// listenFDsStart is the first file descriptor passed by the systemd socket activation or the
+// `Air#Upgrade()`.
+var listenFDsStart = 3
+
+// listen returns the listeners that the s to accept the HTTP connections on and their configs.
+// The listeners inherited from the `Air#Upgrade()` or the systemd socket activation win over the
+// listening addresses.
+func (s *server) listen() ([]net.Listener, []ListenerConfig, error) {
+	lcs := s.listenerConfigs()
+
+	ls, ready, err := upgradeListeners()
+	if err != nil {
+		return nil, nil, err
+	} else if len(ls) > 0 {
+		s.upgradeReady = ready
+	} else if ls, err = systemdListeners(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(ls) > 0 {
+		if len(lcs) > len(ls) {
+			lcs = lcs[:len(ls)]
+		}
+		for len(lcs) < len(ls) {
+			lcs = append(lcs, ListenerConfig{})
+		}
+		return ls, lcs, nil
+	}
+
+	for _, lc := range lcs {
+		l, err := listen(lc.Address, s.air.Config.UnixSocketMode)
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+			return nil, nil, err
+		}
+
+		ls = append(ls, l)
+	}
+
+	return ls, lcs, nil
+}
+
+// listenerConfigs returns a copy of the `Config#Listeners`, or the one returned by the
+// `defaultListenerConfig()` if it is empty.
+func (s *server) listenerConfigs() []ListenerConfig {
+	if len(s.air.Config.Listeners) == 0 {
+		return []ListenerConfig{s.defaultListenerConfig()}
+	}
+	return append([]ListenerConfig(nil), s.air.Config.Listeners...)
+}
+
+// defaultListenerConfig returns the `ListenerConfig` made of the `Config#Address`, the
+// `Config#TLSCertFile` and the `Config#TLSKeyFile`. The first one of the `Config#TLSCertificates`
+// will be used if the `Config#TLSCertFile` or the `Config#TLSKeyFile` is empty.
+func (s *server) defaultListenerConfig() ListenerConfig {
+	c := s.air.Config
+
+	lc := ListenerConfig{
+		Address:     c.Address,
+		TLSCertFile: c.TLSCertFile,
+		TLSKeyFile:  c.TLSKeyFile,
+	}
+
+	if (lc.TLSCertFile == "" || lc.TLSKeyFile == "") && len(c.TLSCertificates) > 0 {
+		lc.TLSCertFile = c.TLSCertificates[0].CertFile
+		lc.TLSKeyFile = c.TLSCertificates[0].KeyFile
+	}
+
+	return lc
+}
+
+// httpsPort returns the port of the first listener in the lcs that has the TLS settings. It
+// returns "443" if there is no such listener.
+func httpsPort(lcs []ListenerConfig) string {
+	for _, lc := range lcs {
+		if lc.TLSCertFile == "" || lc.TLSKeyFile == "" {
+			continue
+		} else if _, port, err := net.SplitHostPort(lc.Address); err == nil {
+			return port
+		}
+	}
+	return "443"
+}
+
+// limitListener is a listener that limits the number of the connections open at the same time.
+type limitListener struct {
+	net.Listener
+
+	air       *Air
+	slots     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// limitListenerConn is a connection accepted by the `limitListener`. It releases its slot once it
+// is closed.
+type limitListenerConn struct {
+	net.Conn
+
+	release     func()
+	releaseOnce sync.Once
+}
+
+// newLimitListener returns a pointer of a new instance of the `limitListener` that wraps the l.
+// The slots may be shared by multiple `limitListener`s, and its capacity is the maximum number of
+// the connections.
+func newLimitListener(a *Air, l net.Listener, slots chan struct{}) *limitListener {
+	return &limitListener{
+		Listener: l,
+		air:      a,
+		slots:    slots,
+		done:     make(chan struct{}),
+	}
+}
+
+// Accept implements the `net.Listener`. It waits for a free slot before accepting when the maximum
+// number of the connections is reached.
+func (l *limitListener) Accept() (net.Conn, error) {
+	acquired := l.acquire()
+
+	c, err := l.Listener.Accept()
+	if err != nil {
+		if acquired {
+			l.release()
+		}
+		return nil, err
+	}
+
+	return &limitListenerConn{
+		Conn:    c,
+		release: l.release,
+	}, nil
+}
+
+// Close implements the `net.Listener`.
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return l.Listener.Close()
+}
+
+// acquire acquires a slot of the l. It reports false if the l is closed before a slot is free.
+func (l *limitListener) acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	l.air.Logger.Warnf(
+		"the maximum number of the connections %d has been reached",
+		cap(l.slots),
+	)
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// release releases a slot of the l.
+func (l *limitListener) release() {
+	<-l.slots
+}
+
+// Close implements the `net.Conn`.
+func (c *limitListenerConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
+
+// httpsRedirectListener is a listener whose connections are marked for redirecting every HTTP
+// request to the HTTPS origin.
+type httpsRedirectListener struct {
+	net.Listener
+}
+
+// httpsRedirectConn is a connection accepted by the `httpsRedirectListener`.
+type httpsRedirectConn struct {
+	net.Conn
+}
+
+// Accept implements the `net.Listener`.
+func (l httpsRedirectListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return httpsRedirectConn{c}, nil
+}
+
+// listen listens on the address. It listens on a Unix domain socket with the mode if the address
+// starts with the "unix:", otherwise on a TCP address.
+func listen(address string, mode os.FileMode) (net.Listener, error) {
+	if !strings.HasPrefix(address, unixAddressPrefix) {
+		return net.Listen("tcp", address)
+	}
+
+	name := address[len(unixAddressPrefix):]
+	if name == "" {
+		return nil, fmt.Errorf("the address %q is invalid", address)
+	}
+
+	// Remove the stale socket file left by the previous process.
+	if fi, err := os.Stat(name); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", name); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("the address %q is already in use", address)
+		} else if err := os.Remove(name); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(name, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// systemdListeners returns the listeners inherited from the systemd socket activation. It returns
+// nil if the "LISTEN_PID" is not the current process or the "LISTEN_FDS" is not set. The
+// environment variables will be unset once they are used.
+func systemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	return fileListeners(n, names)
+}
+
+// fileListeners returns the listeners of the n file descriptors starting from the
+// `listenFDsStart`. The names are used to name the files of them.
+func fileListeners(n int, names []string) ([]net.Listener, error) {
+	ls := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFDsStart + i
+
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+			return nil, err
+		}
+
+		ls = append(ls, l)
+	}
+
+	return ls, nil
+}
@@ -0,0 +1,372 @@
+package air
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirServeListener(t *testing.T) {
+	a := New()
+	a.GET("/", func(c *Context) error {
+		return c.String("listener")
+	})
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+
+	served := make(chan error)
+	go func() {
+		served <- a.ServeListener(l)
+	}()
+
+	waitForServer(l.Addr().String())
+
+	res, err := http.Get("http://" + l.Addr().String())
+	assert.NoError(t, err)
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "listener", string(b))
+
+	assert.NoError(t, a.Close())
+	assert.NoError(t, <-served)
+}
+
+func TestAirServeUnixSocket(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "air.sock")
+
+	a := New()
+	a.Config.Address = unixAddressPrefix + name
+	a.Config.UnixSocketMode = 0600
+	a.GET("/", func(c *Context) error {
+		return c.String("unix")
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	fi, err := os.Stat(name)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	_, err = listen(a.Config.Address, 0600)
+	assert.Error(t, err)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(
+				ctx context.Context,
+				network string,
+				address string,
+			) (net.Conn, error) {
+				return net.Dial("unix", name)
+			},
+		},
+	}
+
+	res, err := client.Get("http://air/")
+	assert.NoError(t, err)
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "unix", string(b))
+
+	assert.NoError(t, a.Close())
+	assert.NoError(t, <-served)
+}
+
+func TestListenStaleUnixSocket(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "air.sock")
+
+	ul, _ := net.ListenUnix("unix", &net.UnixAddr{Name: name, Net: "unix"})
+	ul.SetUnlinkOnClose(false)
+	ul.Close()
+
+	l, err := listen(unixAddressPrefix+name, 0666)
+	assert.NoError(t, err)
+	assert.NotNil(t, l)
+	l.Close()
+
+	_, err = listen(unixAddressPrefix, 0666)
+	assert.Error(t, err)
+}
+
+func TestAirServeListeners(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCertificate(dir, "localhost")
+
+	a := New()
+	a.Config.Listeners = []ListenerConfig{
+		{Address: freeAddress(), HTTPSRedirect: true},
+		{Address: freeAddress(), TLSCertFile: certFile, TLSKeyFile: keyFile},
+		{Address: freeAddress()},
+	}
+	a.GET("/foo", func(c *Context) error {
+		return c.String("foo")
+	})
+	a.GET("/.well-known/acme-challenge/:token", func(c *Context) error {
+		return c.String(c.Param("token"))
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	for _, lc := range a.Config.Listeners {
+		waitForServer(lc.Address)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	_, port, _ := net.SplitHostPort(a.Config.Listeners[1].Address)
+
+	res, err := client.Post(
+		"http://"+a.Config.Listeners[0].Address+"/foo?bar=1",
+		MIMETextPlain,
+		nil,
+	)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusPermanentRedirect, res.StatusCode)
+	assert.Equal(
+		t,
+		"https://127.0.0.1:"+port+"/foo?bar=1",
+		res.Header.Get("Location"),
+	)
+
+	bodies := map[string]string{
+		"http://" + a.Config.Listeners[0].Address + "/.well-known/acme-challenge/x": "x",
+		"https://" + a.Config.Listeners[1].Address + "/foo":                         "foo",
+		"http://" + a.Config.Listeners[2].Address + "/foo":                          "foo",
+	}
+	for u, body := range bodies {
+		res, err := client.Get(u)
+		assert.NoError(t, err)
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal(t, body, string(b), u)
+	}
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+
+	for _, lc := range a.Config.Listeners {
+		_, err := net.Dial("tcp", lc.Address)
+		assert.Error(t, err)
+	}
+}
+
+func TestAirServeListenersError(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+
+	a := New()
+	a.Config.Listeners = []ListenerConfig{
+		{Address: freeAddress()},
+		{Address: l.Addr().String()},
+	}
+
+	assert.Error(t, a.Serve())
+}
+
+func TestAirServeConnectionSettings(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.ReadHeaderTimeout = 50 * time.Millisecond
+	a.Config.IdleTimeout = time.Second
+	a.Config.KeepAliveEnabled = false
+	a.Config.MaxConnections = 1
+	a.GET("/", func(c *Context) error {
+		return c.String("settings")
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	conn, err := net.Dial("tcp", a.Config.Address)
+	assert.NoError(t, err)
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: air\r\n\r\n"))
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.NoError(t, err)
+	assert.True(t, res.Close)
+	conn.Close()
+
+	conn, err = net.Dial("tcp", a.Config.Address)
+	assert.NoError(t, err)
+	conn.Write([]byte("GET / HTTP/1.1\r\n"))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.False(t, strings.Contains(err.Error(), "timeout"))
+	conn.Close()
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+	assert.Equal(t, 50*time.Millisecond, a.server.ReadHeaderTimeout)
+	assert.Equal(t, time.Second, a.server.IdleTimeout)
+}
+
+func TestLimitListener(t *testing.T) {
+	a := New()
+	a.Config.LoggerEnabled = true
+
+	buf := &bytes.Buffer{}
+	a.Logger.SetOutput(buf)
+
+	tl, _ := net.Listen("tcp", "127.0.0.1:0")
+	l := newLimitListener(a, tl, make(chan struct{}, 1))
+
+	accepted := make(chan net.Conn)
+	accept := func() {
+		c, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- c
+	}
+
+	go accept()
+	conn1, _ := net.Dial("tcp", tl.Addr().String())
+	defer conn1.Close()
+	c1 := <-accepted
+
+	go accept()
+	conn2, _ := net.Dial("tcp", tl.Addr().String())
+	defer conn2.Close()
+
+	select {
+	case <-accepted:
+		t.Fatal("the connection should not be accepted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, c1.Close())
+	assert.Error(t, c1.Close())
+	c2 := <-accepted
+	assert.NotNil(t, c2)
+	assert.Contains(t, buf.String(), "the maximum number of the connections 1")
+
+	go accept()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, l.Close())
+	_, ok := <-accepted
+	assert.False(t, ok)
+	assert.Len(t, l.slots, 1)
+
+	c2.Close()
+	assert.Len(t, l.slots, 0)
+}
+
+func TestServerRedirectToHTTPS(t *testing.T) {
+	s := newServer(New())
+
+	cases := []struct {
+		port     string
+		host     string
+		location string
+	}{
+		{"443", "example.com:80", "https://example.com/foo?bar=1"},
+		{"443", "[::1]", "https://[::1]/foo?bar=1"},
+		{"8443", "[::1]:8080", "https://[::1]:8443/foo?bar=1"},
+	}
+
+	for _, c := range cases {
+		s.httpsPort = c.port
+
+		req, _ := http.NewRequest(GET, "/foo?bar=1", nil)
+		req.Host = c.host
+		rec := httptest.NewRecorder()
+		s.redirectToHTTPS(rec, req)
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, c.location, rec.Header().Get("Location"))
+	}
+
+	assert.Equal(t, "443", httpsPort([]ListenerConfig{{Address: ":80"}}))
+	assert.Equal(t, "8443", httpsPort([]ListenerConfig{
+		{Address: ":80"},
+		{Address: ":8443", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"},
+	}))
+}
+
+// writeCertificate writes a self-signed certificate for the host and its key into the dir and
+// returns the paths of them.
+func writeCertificate(dir, host string) (certFile, keyFile string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{host},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	kb, _ := x509.MarshalECPrivateKey(key)
+
+	certFile = filepath.Join(dir, host+".crt")
+	keyFile = filepath.Join(dir, host+".key")
+
+	ioutil.WriteFile(
+		certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		0600,
+	)
+	ioutil.WriteFile(
+		keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}),
+		0600,
+	)
+
+	return
+}
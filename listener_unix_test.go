@@ -0,0 +1,49 @@
+//go:build !windows
+// +build !windows
+
+package air
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemdListeners(t *testing.T) {
+	defer func(start int) {
+		listenFDsStart = start
+	}(listenFDsStart)
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+
+	ls, err := systemdListeners()
+	assert.NoError(t, err)
+	assert.Nil(t, ls)
+
+	tl, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer tl.Close()
+
+	// The file descriptor is duplicated since it will be closed by the
+	// `systemdListeners()` and must not be closed again by the `os.File`.
+	f, _ := tl.(*net.TCPListener).File()
+	listenFDsStart, _ = syscall.Dup(int(f.Fd()))
+	f.Close()
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+	os.Setenv("LISTEN_FDNAMES", "http")
+
+	ls, err = systemdListeners()
+	assert.NoError(t, err)
+	assert.Len(t, ls, 1)
+	assert.Equal(t, tl.Addr().String(), ls[0].Addr().String())
+	assert.Empty(t, os.Getenv("LISTEN_PID"))
+	assert.Empty(t, os.Getenv("LISTEN_FDS"))
+	assert.Empty(t, os.Getenv("LISTEN_FDNAMES"))
+	ls[0].Close()
+}
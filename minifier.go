@@ -18,6 +18,9 @@ import (
 type (
 	// Minifier is used to provide a `Minify()` method for an `Air` instance for minifies a
 	// content by a MIME type.
+	//
+	// If the `Minifier` also implements the `io.Closer`, its `Close()` will be called in the
+	// `Air#Close()` and the `Air#Shutdown()`.
 	Minifier interface {
 		// Init initializes the `Minifier`. It will be called in the `Air#Serve()`.
 		Init() error
@@ -0,0 +1,263 @@
+package air
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtocolV2Signature is the signature of the PROXY protocol v2 header.
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// errInvalidProxyProtocolHeader is the error of the invalid PROXY protocol headers.
+var errInvalidProxyProtocolHeader = errors.New("the PROXY protocol header is invalid")
+
+// proxyProtocolListener is a listener whose connections from the trusted sources take the
+// addresses from the PROXY protocol v1 or v2 header.
+type proxyProtocolListener struct {
+	net.Listener
+
+	trustedCIDRs []*net.IPNet
+	timeout      time.Duration
+}
+
+// proxyProtocolConn is a connection accepted by the `proxyProtocolListener`. The PROXY protocol
+// header will be read when any of its `Read()`, `RemoteAddr()` and `LocalAddr()` is called for the
+// first time.
+type proxyProtocolConn struct {
+	net.Conn
+
+	reader     *bufio.Reader
+	timeout    time.Duration
+	once       sync.Once
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	err        error
+}
+
+// newProxyProtocolListener returns a pointer of a new instance of the `proxyProtocolListener`
+// that wraps the l. The trustedCIDRs are parsed by the `net.ParseCIDR()`, and none of the TCP
+// sources is trusted if they are empty. The PROXY protocol header must be received within the
+// timeout if it is greater than zero.
+func newProxyProtocolListener(
+	l net.Listener,
+	trustedCIDRs []string,
+	timeout time.Duration,
+) (*proxyProtocolListener, error) {
+	ppl := &proxyProtocolListener{
+		Listener: l,
+		timeout:  timeout,
+	}
+
+	for _, tc := range trustedCIDRs {
+		_, n, err := net.ParseCIDR(tc)
+		if err != nil {
+			return nil, err
+		}
+		ppl.trustedCIDRs = append(ppl.trustedCIDRs, n)
+	}
+
+	return ppl, nil
+}
+
+// Accept implements the `net.Listener`.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	} else if !l.trusts(c.RemoteAddr()) {
+		return c, nil
+	}
+
+	return &proxyProtocolConn{
+		Conn:    c,
+		reader:  bufio.NewReader(c),
+		timeout: l.timeout,
+	}, nil
+}
+
+// trusts reports whether the addr is a trusted source of the l. The addresses that are not TCP
+// addresses, such as the ones of the Unix domain sockets, are always trusted.
+func (l *proxyProtocolListener) trusts(addr net.Addr) bool {
+	ta, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+
+	for _, n := range l.trustedCIDRs {
+		if n.Contains(ta.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read implements the `net.Conn`.
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr implements the `net.Conn`. It returns the source address in the PROXY protocol
+// header if there is one.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr implements the `net.Conn`. It returns the destination address in the PROXY protocol
+// header if there is one.
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.readHeader()
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readHeader reads the PROXY protocol header of the c once.
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+
+		c.remoteAddr, c.localAddr, c.err = readProxyProtocolHeader(c.reader)
+	})
+}
+
+// readProxyProtocolHeader reads the PROXY protocol v1 or v2 header from the r and returns the
+// source address and the destination address in it. It returns nil addresses if there is no
+// header or the header does not carry the addresses.
+func readProxyProtocolHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch b[0] {
+	case 'P':
+		if b, err := r.Peek(6); err == nil && string(b) == "PROXY " {
+			return readProxyProtocolV1Header(r)
+		}
+	case '\r':
+		b, err := r.Peek(len(proxyProtocolV2Signature))
+		if err == nil && bytes.Equal(b, proxyProtocolV2Signature) {
+			return readProxyProtocolV2Header(r)
+		}
+	}
+
+	return nil, nil, nil
+}
+
+// readProxyProtocolV1Header reads the PROXY protocol v1 header from the r, such as
+// "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func readProxyProtocolV1Header(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, nil, err
+	} else if len(line) > 107 || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errInvalidProxyProtocolHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	} else if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errInvalidProxyProtocolHeader
+	}
+
+	src, err := proxyProtocolV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dst, err := proxyProtocolV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return src, dst, nil
+}
+
+// proxyProtocolV1Addr returns the TCP address made of the ip and the port in the PROXY protocol
+// v1 header.
+func proxyProtocolV1Addr(ip, port string) (*net.TCPAddr, error) {
+	a := &net.TCPAddr{IP: net.ParseIP(ip)}
+	if a.IP == nil {
+		return nil, errInvalidProxyProtocolHeader
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errInvalidProxyProtocolHeader
+	}
+
+	a.Port = int(p)
+
+	return a, nil
+}
+
+// readProxyProtocolV2Header reads the binary PROXY protocol v2 header from the r.
+func readProxyProtocolV2Header(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	} else if header[12]>>4 != 2 {
+		return nil, nil, errInvalidProxyProtocolHeader
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch header[12] & 0xf {
+	case 0x0: // LOCAL
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, errInvalidProxyProtocolHeader
+	}
+
+	var ipLen int
+	switch header[13] >> 4 {
+	case 0x1: // AF_INET
+		ipLen = net.IPv4len
+	case 0x2: // AF_INET6
+		ipLen = net.IPv6len
+	default: // AF_UNSPEC and AF_UNIX
+		return nil, nil, nil
+	}
+
+	if len(payload) < 2*ipLen+4 {
+		return nil, nil, errInvalidProxyProtocolHeader
+	}
+
+	src := &net.TCPAddr{
+		IP:   net.IP(payload[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+
+	dst := &net.TCPAddr{
+		IP:   net.IP(payload[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+
+	return src, dst, nil
+}
@@ -0,0 +1,189 @@
+package air
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirServeProxyProtocol(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.ProxyProtocolEnabled = true
+	a.Config.ProxyProtocolTrustedCIDRs = []string{"127.0.0.0/8"}
+	a.Config.ReadHeaderTimeout = time.Second
+	a.GET("/", func(c *Context) error {
+		return c.String(c.Request.RemoteAddr)
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	get := func(header string) string {
+		conn, err := net.Dial("tcp", a.Config.Address)
+		if err != nil {
+			return err.Error()
+		}
+		defer conn.Close()
+
+		conn.Write([]byte(header + "GET / HTTP/1.1\r\nHost: air\r\n\r\n"))
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return err.Error()
+		}
+		defer res.Body.Close()
+
+		b, _ := ioutil.ReadAll(res.Body)
+		return string(b)
+	}
+
+	assert.Equal(
+		t,
+		"192.0.2.1:56324",
+		get("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"),
+	)
+	assert.Equal(t, "192.0.2.3:56324", get(string(proxyProtocolV2Header(
+		0x21,
+		0x11,
+		[]byte{192, 0, 2, 3, 192, 0, 2, 2, 0xdc, 0x04, 0x01, 0xbb},
+	))))
+	assert.True(t, strings.HasPrefix(get(""), "127.0.0.1:"))
+	assert.True(t, strings.HasPrefix(get("PROXY UNKNOWN\r\n"), "127.0.0.1:"))
+	assert.Equal(t, "400 Bad Request", get("PROXY TCP4 192.0.2.1\r\n"))
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+
+	a = New()
+	a.Config.Address = freeAddress()
+	a.Config.ProxyProtocolEnabled = true
+	a.GET("/", func(c *Context) error {
+		return c.String(c.Request.RemoteAddr)
+	})
+
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	assert.Equal(t, "400 Bad Request", get("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"))
+	assert.True(t, strings.HasPrefix(get(""), "127.0.0.1:"))
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+
+	a = New()
+	a.Config.Address = freeAddress()
+	a.Config.ProxyProtocolEnabled = true
+	a.Config.ProxyProtocolTrustedCIDRs = []string{"foobar"}
+	assert.Error(t, a.Serve())
+}
+
+func TestProxyProtocolListenerTrusts(t *testing.T) {
+	l, _ := newProxyProtocolListener(nil, nil, 0)
+	assert.False(t, l.trusts(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")}))
+	assert.False(t, l.trusts(&net.TCPAddr{IP: net.ParseIP("127.0.0.1")}))
+	assert.True(t, l.trusts(&net.UnixAddr{Name: "@", Net: "unix"}))
+
+	l, _ = newProxyProtocolListener(nil, []string{"10.0.0.0/8", "::1/128"}, 0)
+	assert.True(t, l.trusts(&net.TCPAddr{IP: net.ParseIP("10.1.2.3")}))
+	assert.True(t, l.trusts(&net.TCPAddr{IP: net.ParseIP("::1")}))
+	assert.False(t, l.trusts(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")}))
+	assert.True(t, l.trusts(&net.UnixAddr{Name: "@", Net: "unix"}))
+}
+
+func TestReadProxyProtocolHeader(t *testing.T) {
+	cases := []struct {
+		header string
+		src    string
+		dst    string
+		err    bool
+	}{
+		{"GET / HTTP/1.1\r\n", "", "", false},
+		{"POST / HTTP/1.1\r\n", "", "", false},
+		{
+			"PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n",
+			"192.0.2.1:56324",
+			"192.0.2.2:443",
+			false,
+		},
+		{
+			"PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n",
+			"[2001:db8::1]:56324",
+			"[2001:db8::2]:443",
+			false,
+		},
+		{"PROXY UNKNOWN\r\n", "", "", false},
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\n", "", "", true},
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324 65536\r\n", "", "", true},
+		{"PROXY TCP4 foo 192.0.2.2 56324 443\r\n", "", "", true},
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\n", "", "", true},
+		{"PROXY " + strings.Repeat("0", 101) + "\r\n", "", "", true},
+		{
+			string(proxyProtocolV2Header(0x21, 0x11, []byte{
+				192, 0, 2, 1, 192, 0, 2, 2, 0xdc, 0x04, 0x01, 0xbb,
+				0x04, 0x00, 0x01, 0x00, // TLV
+			})),
+			"192.0.2.1:56324",
+			"192.0.2.2:443",
+			false,
+		},
+		{
+			string(proxyProtocolV2Header(0x21, 0x21, append(append(
+				net.ParseIP("2001:db8::1"),
+				net.ParseIP("2001:db8::2")...,
+			), 0xdc, 0x04, 0x01, 0xbb))),
+			"[2001:db8::1]:56324",
+			"[2001:db8::2]:443",
+			false,
+		},
+		{string(proxyProtocolV2Header(0x20, 0x00, nil)), "", "", false},
+		{string(proxyProtocolV2Header(0x21, 0x31, make([]byte, 216))), "", "", false},
+		{string(proxyProtocolV2Header(0x11, 0x11, make([]byte, 12))), "", "", true},
+		{string(proxyProtocolV2Header(0x22, 0x11, make([]byte, 12))), "", "", true},
+		{string(proxyProtocolV2Header(0x21, 0x11, make([]byte, 8))), "", "", true},
+	}
+
+	for _, c := range cases {
+		r := bufio.NewReader(strings.NewReader(c.header + "GET / HTTP/1.1\r\n"))
+		src, dst, err := readProxyProtocolHeader(r)
+		if c.err {
+			assert.Error(t, err, c.header)
+			continue
+		}
+
+		assert.NoError(t, err, c.header)
+		if c.src == "" {
+			assert.Nil(t, src, c.header)
+			assert.Nil(t, dst, c.header)
+		} else {
+			assert.Equal(t, c.src, src.String(), c.header)
+			assert.Equal(t, c.dst, dst.String(), c.header)
+		}
+
+		if !strings.HasPrefix(c.header, "GET") && !strings.HasPrefix(c.header, "POST") {
+			line, _ := r.ReadString('\n')
+			assert.Equal(t, "GET / HTTP/1.1\r\n", line, c.header)
+		}
+	}
+}
+
+// proxyProtocolV2Header returns a PROXY protocol v2 header with the verCmd, the fam and the
+// payload.
+func proxyProtocolV2Header(verCmd, fam byte, payload []byte) []byte {
+	b := append([]byte{}, proxyProtocolV2Signature...)
+	b = append(b, verCmd, fam, byte(len(payload)>>8), byte(len(payload)))
+	return append(b, payload...)
+}
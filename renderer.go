@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -15,6 +16,9 @@ import (
 type (
 	// Renderer is used to provide a `Render()` method for an `Air` instance for renders a
 	// "text/html" HTTP response.
+	//
+	// If the `Renderer` also implements the `io.Closer`, its `Close()` will be called in the
+	// `Air#Close()` and the `Air#Shutdown()`.
 	Renderer interface {
 		// Init initializes the `Renderer`. It will be called in the `Air#Serve()`.
 		Init() error
@@ -33,6 +37,8 @@ type (
 		template        *template.Template
 		templateFuncMap template.FuncMap
 		watcher         *fsnotify.Watcher
+		watcherDone     chan struct{}
+		watcherMutex    sync.Mutex
 	}
 )
 
@@ -73,28 +79,66 @@ func (r *renderer) Init() error {
 		return nil
 	}
 
-	tr, err := filepath.Abs(c.TemplateRoot)
+	dirs, err := r.load()
 	if err != nil {
 		return err
 	}
 
-	dirs, files, err := walkFiles(tr, c.TemplateExts)
-	if err != nil {
-		return err
-	}
+	r.watcherMutex.Lock()
+	defer r.watcherMutex.Unlock()
 
 	if r.watcher == nil {
-		if r.watcher, err = fsnotify.NewWatcher(); err != nil {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
 			return err
 		}
 
 		for _, dir := range dirs {
-			if err := r.watcher.Add(dir); err != nil {
+			if err := w.Add(dir); err != nil {
+				w.Close()
 				return err
 			}
 		}
 
-		go r.watchTemplates()
+		r.watcher = w
+		r.watcherDone = make(chan struct{})
+
+		go r.watchTemplates(w, r.watcherDone)
+	}
+
+	return nil
+}
+
+// Close implements the `io.Closer`. It stops watching the template files and waits for the
+// watching goroutine to exit. The r can be initialized again after closing.
+func (r *renderer) Close() error {
+	r.watcherMutex.Lock()
+	w, done := r.watcher, r.watcherDone
+	r.watcher, r.watcherDone = nil, nil
+	r.watcherMutex.Unlock()
+
+	if w == nil {
+		return nil
+	}
+
+	err := w.Close()
+	<-done
+
+	return err
+}
+
+// load parses all template files into the r and returns all dirs of them.
+func (r *renderer) load() ([]string, error) {
+	c := r.air.Config
+
+	tr, err := filepath.Abs(c.TemplateRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, files, err := walkFiles(tr, c.TemplateExts)
+	if err != nil {
+		return nil, err
 	}
 
 	t := template.New("template")
@@ -104,24 +148,24 @@ func (r *renderer) Init() error {
 	for _, file := range files {
 		b, err := ioutil.ReadFile(file)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if c.TemplateMinified {
 			if b, err = r.air.Minifier.Minify(MIMETextHTML, b); err != nil {
-				return err
+				return nil, err
 			}
 		}
 
 		name := filepath.ToSlash(file[len(tr)+1:])
 		if _, err = t.New(name).Parse(string(b)); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	r.template = t
 
-	return nil
+	return dirs, nil
 }
 
 // SetTemplateFunc implements the `Renderer#SetTemplateFunc()` by using the `template.Template`.
@@ -134,21 +178,31 @@ func (r *renderer) Render(w io.Writer, templateName string, data Map) error {
 	return r.template.ExecuteTemplate(w, templateName, data)
 }
 
-// watchTemplates watchs the changing of all template files.
-func (r *renderer) watchTemplates() {
+// watchTemplates watchs the changing of all template files by using the w until the w is closed.
+// The done will be closed when it returns.
+func (r *renderer) watchTemplates(w *fsnotify.Watcher, done chan struct{}) {
+	defer close(done)
 	for {
 		select {
-		case event := <-r.watcher.Events:
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+
 			r.air.Logger.Info(event)
 
 			if event.Op == fsnotify.Create {
-				r.watcher.Add(event.Name)
+				w.Add(event.Name)
 			}
 
-			if err := r.Init(); err != nil {
+			if _, err := r.load(); err != nil {
 				r.air.Logger.Error(err)
 			}
-		case err := <-r.watcher.Errors:
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+
 			r.air.Logger.Error(err)
 		}
 	}
@@ -2,6 +2,7 @@ package air
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
 	"testing"
 	"time"
@@ -106,6 +107,30 @@ func TestRendererInitAndRender(t *testing.T) {
 	assert.Equal(t, result, b.String())
 }
 
+func TestRendererClose(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	a := New()
+	a.Config.TemplateRoot = dir
+
+	r := a.Renderer.(*renderer)
+	assert.NoError(t, r.Close())
+
+	assert.NoError(t, r.Init())
+	assert.NotNil(t, r.watcher)
+	done := r.watcherDone
+
+	assert.NoError(t, r.Close())
+	assert.Nil(t, r.watcher)
+	_, ok := <-done
+	assert.False(t, ok)
+
+	assert.NoError(t, r.Init())
+	assert.NotNil(t, r.watcher)
+	assert.NoError(t, r.Close())
+}
+
 func TestRendererTemplateFuncs(t *testing.T) {
 	assert.Equal(t, 9, strlen("Hello, 世界"))
 	assert.Equal(t, "Air Web Framework", strcat("Air ", "Web ", "Framework"))
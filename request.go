@@ -3,6 +3,7 @@ package air
 import (
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // Request represents the current HTTP request.
@@ -29,6 +30,20 @@ func (r *Request) Bind(i interface{}) error {
 	return r.context.Air.Binder.Bind(i, r)
 }
 
+// Scheme returns the scheme of the r. It will be the "https" if the r is over the TLS, or the
+// value of the "X-Forwarded-Proto" header if it is "http" or "https", otherwise the "http".
+//
+// The "X-Forwarded-Proto" header is trusted as is, so it should only be set by the trusted
+// reverse proxies.
+func (r *Request) Scheme() string {
+	if r.TLS != nil {
+		return "https"
+	} else if strings.EqualFold(r.Header.Get(HeaderXForwardedProto), "https") {
+		return "https"
+	}
+	return "http"
+}
+
 // FormValues returns the form values.
 func (r *Request) FormValues() url.Values {
 	if r.Form == nil {
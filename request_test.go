@@ -1,6 +1,7 @@
 package air
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
 	"net/http"
@@ -126,6 +127,27 @@ func TestRequestBind(t *testing.T) {
 	assert.Equal(t, *raw, *i)
 }
 
+func TestRequestScheme(t *testing.T) {
+	a := New()
+	c := NewContext(a)
+	req, _ := http.NewRequest(GET, "/", nil)
+	c.feed(req, nil)
+	assert.Equal(t, "http", c.Request.Scheme())
+
+	req.Header.Set(HeaderXForwardedProto, "https")
+	assert.Equal(t, "https", c.Request.Scheme())
+
+	req.Header.Set(HeaderXForwardedProto, "HTTPS")
+	assert.Equal(t, "https", c.Request.Scheme())
+
+	req.Header.Set(HeaderXForwardedProto, "javascript")
+	assert.Equal(t, "http", c.Request.Scheme())
+
+	req.Header.Del(HeaderXForwardedProto)
+	req.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "https", c.Request.Scheme())
+}
+
 func TestRequestFormFile(t *testing.T) {
 	a := New()
 	c := NewContext(a)
@@ -45,6 +45,9 @@ func (r *Response) Write(b []byte) (int, error) {
 	if !r.Written {
 		r.WriteHeader(r.StatusCode)
 	}
+	if req := r.context.Request.Request; req != nil && req.Method == HEAD {
+		return len(b), nil // The body of the HEAD responses is always discarded
+	}
 	n, err := r.ResponseWriter.Write(b)
 	r.Size += n
 	return n, err
@@ -186,7 +189,7 @@ func (r *Response) NoContent() error { return nil }
 
 // Redirect redirects the current HTTP request to the url with the statusCode.
 func (r *Response) Redirect(statusCode int, url string) error {
-	if statusCode < http.StatusMultipleChoices || statusCode > http.StatusTemporaryRedirect {
+	if statusCode < http.StatusMultipleChoices || statusCode > http.StatusPermanentRedirect {
 		return ErrInvalidRedirectCode
 	}
 	r.Header().Set(HeaderLocation, url)
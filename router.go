@@ -1,7 +1,11 @@
 package air
 
 import (
+	"bytes"
 	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 	"unsafe"
 )
@@ -10,17 +14,58 @@ type (
 	// router is the registry of all registered routes for an `Air` instance for the HTTP
 	// request matching and the HTTP URL path params parsing.
 	router struct {
-		air *Air
+		air  *Air
+		host *host
+
+		version     string
+		routes      map[string]*Route
+		groups      []*Group
+		tree        *node
+		constraints map[string]*paramConstraint
+	}
+
+	// Route is a route registered in the router of an `Air` instance. It contains a handler and
+	// information for matching against the HTTP requests.
+	Route struct {
+		router *router
+
+		method      string
+		path        string
+		handler     string
+		name        string
+		gasCount    int
+		groupPrefix string
+	}
+
+	// routeState is the state of a routing. It keeps the backtracking points of the routing to
+	// resume it when a handler returns the `ErrNextRoute`.
+	routeState struct {
+		router *router
+		method string
+		path   string
+		points []routePoint
+	}
 
-		routes map[string]*route
-		tree   *node
+	// routePoint is a backtracking point of a routing. The routing will be resumed from the
+	// param children of the node that come after the child, and then from its any child.
+	routePoint struct {
+		node   *node
+		search string
+		child  *node // Last tried param child
+		pc     int   // Param count
 	}
 
-	// route contains a handler and information for matching against the HTTP requests.
-	route struct {
-		method  string
-		path    string
-		handler string
+	// RouteInfo is the public information of a `Route`.
+	RouteInfo struct {
+		Method      string   `json:"method"`
+		Host        string   `json:"host,omitempty"`
+		Version     string   `json:"version,omitempty"`
+		Path        string   `json:"path"`
+		Name        string   `json:"name,omitempty"`
+		ParamNames  []string `json:"param_names,omitempty"`
+		HandlerName string   `json:"handler_name"`
+		GasCount    int      `json:"gas_count"`
+		GroupPrefix string   `json:"group_prefix,omitempty"`
 	}
 
 	// node is the node of the field `tree` of the `router`.
@@ -33,6 +78,13 @@ type (
 		children      []*node
 		pristinePath  string
 		paramNames    []string
+		constraint    *paramConstraint
+	}
+
+	// paramConstraint is the constraint of the param value of the `node`.
+	paramConstraint struct {
+		pattern string
+		match   func(string) bool
 	}
 
 	// nodekind is the kind of the `node`.
@@ -40,10 +92,17 @@ type (
 
 	// methodHandler is a set of the `Handler` distinguish by method.
 	methodHandler struct {
-		get    Handler
-		post   Handler
-		put    Handler
-		delete Handler
+		allowedMethods []string
+
+		get     Handler
+		head    Handler
+		post    Handler
+		put     Handler
+		patch   Handler
+		delete  Handler
+		connect Handler
+		options Handler
+		trace   Handler
 	}
 )
 
@@ -58,21 +117,54 @@ const (
 func newRouter(a *Air) *router {
 	return &router{
 		air:    a,
-		routes: make(map[string]*route),
+		routes: make(map[string]*Route),
 		tree: &node{
 			methodHandler: &methodHandler{},
 		},
+		constraints: make(map[string]*paramConstraint),
 	}
 }
 
+// paramConstraintFuncs is the set of the built-in param constraints.
+var paramConstraintFuncs = map[string]func(string) bool{
+	"int":   isInt,
+	"uint":  isUint,
+	"alpha": isAlpha,
+	"alnum": isAlnum,
+	"hex":   isHex,
+	"uuid":  isUUID,
+}
+
 // checkPath checks whether the path is valid.
 func (r *router) checkPath(path string) {
+	if path != "" && strings.Contains(path, "<") {
+		ss := strings.Split(path, "/")
+		for i, s := range ss {
+			j := strings.Index(s, ":")
+			k := strings.Index(s, "<")
+			if k < 0 {
+				continue
+			} else if j < 0 || j > k {
+				panic("the < can only appear after a param name")
+			} else if k == j+1 {
+				panic("the param name cannot be empty")
+			} else if s[len(s)-1] != '>' {
+				panic("the param constraint must end with the > and cannot have the /")
+			} else if k == len(s)-2 {
+				panic("the param constraint cannot be empty")
+			}
+			ss[i] = s[:k]
+		}
+		path = strings.Join(ss, "/")
+	}
+
 	if path == "" {
 		panic("the path cannot be empty")
 	} else if path[0] != '/' {
 		panic("the path must start with the /")
-	} else if path != "/" && hasLastSlash(path) {
-		panic("the path cannot end with the /, except the root path")
+	} else if path != "/" && hasLastSlash(path) && !r.air.Config.RouterStrictSlash {
+		panic("the path cannot end with the /, except the root path, unless the " +
+			"Config#RouterStrictSlash is true")
 	} else if strings.Contains(path, "//") {
 		panic("the path cannot have the //")
 	} else if strings.Count(path, ":") > 1 {
@@ -108,6 +200,43 @@ func (r *router) checkRoute(method, path string) {
 	}
 }
 
+// register registers a new route for the path with the method and the matching h with the
+// route-level gases. The gases are composed with the h once here rather than per request. It
+// panics if the method is not one of the supported HTTP methods.
+func (r *router) register(method, path string, h Handler, gases []Gas) *Route {
+	supported := false
+	for _, m := range methods {
+		if m == method {
+			supported = true
+			break
+		}
+	}
+
+	if !supported {
+		panic(fmt.Sprintf("the method %q is not supported", method))
+	}
+
+	hn := handlerName(h)
+
+	for i := len(gases) - 1; i >= 0; i-- {
+		h = gases[i](h)
+	}
+
+	r.add(method, path, h)
+
+	rt := &Route{
+		router:   r,
+		method:   method,
+		path:     path,
+		handler:  hn,
+		gasCount: len(gases),
+	}
+
+	r.routes[method+path] = rt
+
+	return rt
+}
+
 // add registers a new route for the method and the path with the matching h.
 func (r *router) add(method, path string, h Handler) {
 	// Checks
@@ -121,13 +250,19 @@ func (r *router) add(method, path string, h Handler) {
 		if path[i] == ':' {
 			j := i + 1
 
-			r.insert(method, path[:i], nil, staticKind, "", nil)
+			r.insert(method, path[:i], nil, staticKind, "", nil, nil)
 
 			for ; i < l && path[i] != '/'; i++ {
 			}
 
 			pname := path[j:i]
 
+			var pc *paramConstraint
+			if k := strings.IndexByte(pname, '<'); k >= 0 {
+				pc = r.paramConstraint(pname[k+1 : len(pname)-1])
+				pname = pname[:k]
+			}
+
 			for _, pn := range pnames {
 				if pn == pname {
 					panic("the path cannot have duplicate param names")
@@ -135,28 +270,54 @@ func (r *router) add(method, path string, h Handler) {
 			}
 
 			pnames = append(pnames, pname)
-			path = path[:j] + path[i:]
+			path = path[:j] + path[j+len(pname):]
 
-			if i, l = j, len(path); i == l {
-				r.insert(method, path, h, paramKind, ppath, pnames)
+			if i, l = i-len(pname), len(path); i == l {
+				r.insert(method, path, h, paramKind, ppath, pnames, pc)
 				return
 			}
 
-			r.insert(method, path[:i], nil, paramKind, ppath, pnames)
+			r.insert(method, path[:i], nil, paramKind, ppath, pnames, pc)
 		} else if path[i] == '*' {
-			r.insert(method, path[:i], nil, staticKind, "", nil)
+			r.insert(method, path[:i], nil, staticKind, "", nil, nil)
 			pnames = append(pnames, "*")
-			r.insert(method, path[:i+1], h, anyKind, ppath, pnames)
+			r.insert(method, path[:i+1], h, anyKind, ppath, pnames, nil)
 			return
 		}
 	}
 
-	r.insert(method, path, h, staticKind, ppath, pnames)
+	r.insert(method, path, h, staticKind, ppath, pnames, nil)
+}
+
+// paramConstraint returns a `paramConstraint` for the pattern. The pattern can be the name of a
+// built-in constraint ("int", "uint", "alpha", "alnum", "hex" and "uuid") or a regular expression
+// that must match the entire param value.
+func (r *router) paramConstraint(pattern string) *paramConstraint {
+	if pc := r.constraints[pattern]; pc != nil {
+		return pc
+	}
+
+	pc := &paramConstraint{
+		pattern: pattern,
+		match:   paramConstraintFuncs[pattern],
+	}
+
+	if pc.match == nil {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			panic(fmt.Sprintf("the param constraint <%s> is invalid: %v", pattern, err))
+		}
+		pc.match = re.MatchString
+	}
+
+	r.constraints[pattern] = pc
+
+	return pc
 }
 
 // insert inserts a new route into the tree of the r.
 func (r *router) insert(method, path string, h Handler, k nodeKind, ppath string,
-	pnames []string) {
+	pnames []string, pc *paramConstraint) {
 	if l := len(pnames); l > r.air.paramCap {
 		r.air.paramCap = l
 	}
@@ -199,6 +360,7 @@ func (r *router) insert(method, path string, h Handler, k nodeKind, ppath string
 			// Split node
 			nn = newNode(cn.kind, cn.prefix[ll:], cn.methodHandler, cn, cn.children,
 				cn.pristinePath, cn.paramNames)
+			nn.constraint = cn.constraint
 
 			// Reset parent node
 			cn.kind = staticKind
@@ -208,6 +370,7 @@ func (r *router) insert(method, path string, h Handler, k nodeKind, ppath string
 			cn.methodHandler = &methodHandler{}
 			cn.pristinePath = ""
 			cn.paramNames = nil
+			cn.constraint = nil
 
 			cn.addChild(nn)
 
@@ -221,13 +384,20 @@ func (r *router) insert(method, path string, h Handler, k nodeKind, ppath string
 				// Create child node
 				nn = newNode(k, search[ll:], &methodHandler{}, cn, nil, ppath,
 					pnames)
+				nn.constraint = pc
 				nn.addHandler(method, h)
 				cn.addChild(nn)
 			}
 		} else if ll < sl {
 			search = search[ll:]
 
-			if nn = cn.childByLabel(search[0]); nn != nil {
+			if search[0] == ':' {
+				nn = cn.childByPrefix(search[:paramEnd(search)])
+			} else {
+				nn = cn.childByLabel(search[0])
+			}
+
+			if nn != nil {
 				// Go deeper
 				cn = nn
 				continue
@@ -235,6 +405,7 @@ func (r *router) insert(method, path string, h Handler, k nodeKind, ppath string
 
 			// Create child node
 			nn = newNode(k, search, &methodHandler{}, cn, nil, ppath, pnames)
+			nn.constraint = pc
 			nn.addHandler(method, h)
 			cn.addChild(nn)
 		} else if h != nil {
@@ -250,26 +421,182 @@ func (r *router) insert(method, path string, h Handler, k nodeKind, ppath string
 
 // route routes a handler registered for the method and the path. It also parses the HTTP URL for
 // the path params and load them into the c.
+//
+// The non-canonical path will be redirected to the canonical one if the
+// `Config#RouterRedirectCode` is not zero. And the path will be matched case-insensitively if
+// there is no route matched and the `Config#RouterCaseInsensitive` is true.
+//
+// The not-found and the method-not-allowed handlers are chosen from the `Group` that has the
+// longest prefix matching the path, or from the `Air` if there is no such `Group`.
 func (r *router) route(method, path string, c *Context) {
-	cn := r.tree // Current node as root
+	c.Handler = nil
+
+	rc := r.redirectCode()
+	if r.find(method, path, c); c.allowedMethods != nil {
+		if rc != 0 {
+			if cp := r.cleanPath(path); cp != path {
+				r.redirect(c, rc, path, cp)
+			}
+		}
+	} else if rp := r.fixedPath(method, path, c); rp != "" && rc != 0 {
+		r.redirect(c, rc, path, rp)
+	}
+
+	if !methodAllowed(method) {
+		c.Handler = r.fallbackHandler(path, true)
+	} else if c.Handler == nil {
+		c.Handler = r.fallbackHandler(path, c.allowedMethods != nil)
+	}
+}
+
+// redirectCode returns the `Config#RouterRedirectCode`. It falls back to the 301 if the code is
+// not zero and not one of the 301, 302, 303, 307 and 308.
+func (r *router) redirectCode() int {
+	switch rc := r.air.Config.RouterRedirectCode; rc {
+	case 0,
+		http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusSeeOther,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return rc
+	}
+	return http.StatusMovedPermanently
+}
+
+// fixedPath returns the fixed path of the path that has no route matched. The path will be fixed by
+// toggling the last slash when the `Config#RouterStrictSlash` is true and the
+// `Config#RouterRedirectCode` is not zero, or by matching case-insensitively when the
+// `Config#RouterCaseInsensitive` is true. The fixed path will be routed into the c. It returns ""
+// if the path cannot be fixed.
+func (r *router) fixedPath(method, path string, c *Context) string {
+	if r.air.Config.RouterStrictSlash && r.air.Config.RouterRedirectCode != 0 && path != "/" {
+		tp := r.cleanPath(path) // Toggled path
+		if hasLastSlash(tp) {
+			tp = tp[:len(tp)-1]
+		} else {
+			tp += "/"
+		}
+
+		c.ParamValues = c.ParamValues[:0]
+		if r.find(method, tp, c); c.allowedMethods != nil {
+			return tp
+		}
+	}
+
+	if r.air.Config.RouterCaseInsensitive {
+		if b := r.tree.caseInsensitivePath(r.cleanPath(path), nil); b != nil {
+			rp := string(b)
+			c.ParamValues = c.ParamValues[:0]
+			if r.find(method, rp, c); c.allowedMethods != nil {
+				return rp
+			}
+		}
+	}
+
+	return ""
+}
+
+// fallbackHandler returns the not-found `Handler`, or the method-not-allowed `Handler` if the
+// methodNotAllowed is true, for the path. It's chosen from the `Group` that has the longest prefix
+// matching the path and will be wrapped by the gases of the `Group`. The one of the `Air` or the
+// package-level one will be used if there is no such `Group`.
+func (r *router) fallbackHandler(path string, methodNotAllowed bool) Handler {
+	var g *Group
+	cp := r.cleanPath(path)
+	for _, rg := range r.groups {
+		rh := rg.NotFoundHandler
+		if methodNotAllowed {
+			rh = rg.MethodNotAllowedHandler
+		}
+
+		if rh != nil && (g == nil || len(rg.prefix) > len(g.prefix)) &&
+			rg.matchPrefix(cp) {
+			g = rg
+		}
+	}
+
+	if g != nil {
+		return g.fallbackHandler(methodNotAllowed)
+	}
+
+	if methodNotAllowed {
+		if r.air.MethodNotAllowedHandler != nil {
+			return r.air.MethodNotAllowedHandler
+		}
+		return MethodNotAllowedHandler
+	}
+
+	if r.air.NotFoundHandler != nil {
+		return r.air.NotFoundHandler
+	}
+
+	return NotFoundHandler
+}
+
+// find finds a handler registered for the method and the path. It also parses the HTTP URL for the
+// path params and load them into the c.
+func (r *router) find(method, path string, c *Context) {
+	c.routeState.router = r
+	c.routeState.method = method
+	c.routeState.path = path
+	c.routeState.points = c.routeState.points[:0]
+	r.findFrom(c, r.tree, r.cleanPath(path), staticKind, nil)
+}
+
+// next resumes the routing of the c from its last backtracking point to find the next matched
+// route. It reports whether there is a next matched route. The c will be routed to the not-found
+// `Handler` if there is not.
+func (r *router) next(c *Context) bool {
+	rs := &c.routeState
+	for l := len(rs.points); l > 0; l = len(rs.points) {
+		p := rs.points[l-1]
+		rs.points = rs.points[:l-1]
+
+		c.Handler = nil
+		c.PristinePath = ""
+		c.ParamNames = nil
+		c.ParamValues = c.ParamValues[:p.pc]
+		c.allowedMethods = nil
+
+		r.findFrom(c, p.node, p.search, paramKind, p.child)
+		if c.Handler != nil {
+			return true
+		}
+	}
+
+	c.Handler = r.fallbackHandler(rs.path, false)
+
+	return false
+}
+
+// findFrom is like the `find()`, but starts from the n with the search. The search will be started
+// from the param children of the n that come after the pn if the k is the `paramKind`. Every node
+// passed through is pushed into the backtracking points of the c, so a failed search under a node
+// falls through to its next candidate child.
+func (r *router) findFrom(c *Context, n *node, search string, k nodeKind, pn *node) {
+	rs := &c.routeState
+	cn := n // Current node
 
 	var (
-		search = pathClean(path)
-		nn     *node    // Next node
-		nk     nodeKind // Next kind
-		sn     *node    // Saved node
-		ss     string   // Saved search
-		sl     int      // Search length
-		pl     int      // Prefix length
-		ll     int      // LCP length
-		max    int      // Max number of sl and pl
-		si     int      // Start index
+		nn  *node  // Next node
+		pv  string // Param value
+		sl  int    // Search length
+		pl  int    // Prefix length
+		ll  int    // LCP length
+		max int    // Max number of sl and pl
+		si  int    // Start index
 	)
 
 	// Search order: static > param > any
 	for {
+		if k == paramKind {
+			k = staticKind
+			goto Param
+		}
+
 		if search == "" {
-			break
+			goto Found
 		}
 
 		pl = 0
@@ -293,16 +620,18 @@ func (r *router) route(method, path string, c *Context) {
 		}
 
 		if search = search[ll:]; search == "" {
-			break
+			goto Found
 		}
 
 		// Static node
 		if nn = cn.child(search[0], staticKind); nn != nil {
 			// Save next
 			if hasLastSlash(cn.prefix) {
-				nk = paramKind
-				sn = cn
-				ss = search
+				rs.points = append(rs.points, routePoint{
+					node:   cn,
+					search: search,
+					pc:     len(c.ParamValues),
+				})
 			}
 
 			cn = nn
@@ -312,32 +641,40 @@ func (r *router) route(method, path string, c *Context) {
 
 		// Param node
 	Param:
-		if nn = cn.childByKind(paramKind); nn != nil {
+		for si = 0; si < len(search) && search[si] != '/'; si++ {
+		}
+
+		pv = unescape(search[:si])
+
+		if nn = cn.paramChild(pv, pn); nn != nil {
+			pn = nil
+
 			// Save next
 			if hasLastSlash(cn.prefix) {
-				nk = anyKind
-				sn = cn
-				ss = search
+				rs.points = append(rs.points, routePoint{
+					node:   cn,
+					search: search,
+					child:  nn,
+					pc:     len(c.ParamValues),
+				})
 			}
 
 			cn = nn
 
-			for si = 0; si < len(search) && search[si] != '/'; si++ {
-			}
-
-			c.ParamValues = append(c.ParamValues, unescape(search[:si]))
+			c.ParamValues = append(c.ParamValues, pv)
 			search = search[si:]
 
 			continue
 		}
 
 		// Any node
-	Any:
-		if cn = cn.childByKind(anyKind); cn != nil {
-			if hasLastSlash(path) {
-				for si = len(path) - 1; si > 0 && path[si] == '/'; si-- {
+		if nn = cn.childByKind(anyKind); nn != nil {
+			cn = nn
+
+			if hasLastSlash(rs.path) && !hasLastSlash(search) {
+				for si = len(rs.path) - 1; si > 0 && rs.path[si] == '/'; si-- {
 				}
-				search += path[si+1:]
+				search += rs.path[si+1:]
 			}
 
 			if len(c.ParamValues) < len(cn.paramNames) {
@@ -351,30 +688,154 @@ func (r *router) route(method, path string, c *Context) {
 
 		// Struggle for the former node
 	Struggle:
-		if sn != nil {
-			cn = sn
-			sn = nil
-			search = ss
-
-			switch nk {
-			case paramKind:
-				goto Param
-			case anyKind:
-				goto Any
-			}
+		if l := len(rs.points); l > 0 {
+			p := rs.points[l-1]
+			rs.points = rs.points[:l-1]
+
+			cn = p.node
+			search = p.search
+			k = paramKind
+			pn = p.child
+			c.ParamValues = c.ParamValues[:p.pc]
+
+			continue
 		}
 
+		rs.router = nil
+
 		return
+
+		// The node without any route falls through to the former node
+	Found:
+		if cn.methodHandler.allowedMethods == nil {
+			goto Struggle
+		}
+
+		break
 	}
 
-	if c.Handler = cn.handler(method); c.Handler != nil {
+	c.allowedMethods = cn.methodHandler.allowedMethods
+
+	if c.Handler = cn.handler(rs.method); c.Handler != nil {
 		c.PristinePath = cn.pristinePath
 		c.ParamNames = cn.paramNames
 	} else {
-		c.Handler = cn.checkMethodNotAllowed()
+		c.Handler = cn.checkMethodNotAllowed(rs.method)
+	}
+}
+
+// redirect makes the c redirect from the path to the target with the code. The part of the current
+// HTTP request path in front of the path and the query of the current HTTP request will be kept.
+func (r *router) redirect(c *Context, code int, path, target string) {
+	if ep := c.Request.URL.EscapedPath(); len(ep) > len(path) && strings.HasSuffix(ep, path) {
+		target = ep[:len(ep)-len(path)] + target
+	}
+
+	path = target
+	if q := c.Request.URL.RawQuery; q != "" {
+		path += "?" + q
+	}
+
+	c.Handler = func(c *Context) error {
+		return c.Redirect(code, path)
+	}
+}
+
+// cleanPath returns a clean path from the p. The last slash of the p will be kept if the
+// `Config#RouterStrictSlash` is true.
+func (r *router) cleanPath(p string) string {
+	cp := pathClean(p)
+	if r.air.Config.RouterStrictSlash && cp != "/" && hasLastSlash(p) {
+		cp += "/"
+	}
+	return cp
+}
+
+// Name names the r with the name for the `Air#URLFor()`. The routes that share the same path can
+// share the same name.
+func (r *Route) Name(name string) *Route {
+	if nr := r.router.air.routeNames[name]; nr != nil &&
+		(nr.path != r.path || nr.router != r.router) {
+		panic(fmt.Sprintf("the route name %q is already used by the route [%s %s]", name,
+			nr.method, nr.path))
+	}
+
+	r.name = name
+	r.router.air.routeNames[name] = r
+
+	return r
+}
+
+// info returns the `RouteInfo` of the r.
+func (r *Route) info() RouteInfo {
+	ri := RouteInfo{
+		Method:      r.method,
+		Version:     r.router.version,
+		Path:        r.path,
+		Name:        r.name,
+		HandlerName: r.handler,
+		GasCount:    r.gasCount,
+		GroupPrefix: r.groupPrefix,
+	}
+
+	if r.router.host != nil {
+		ri.Host = r.router.host.pattern
 	}
 
-	return
+	for i, l := 0, len(r.path); i < l; i++ {
+		if r.path[i] == ':' {
+			pname, _, n := splitParam(r.path[i+1:])
+			ri.ParamNames = append(ri.ParamNames, pname)
+			i += n
+		} else if r.path[i] == '*' {
+			ri.ParamNames = append(ri.ParamNames, "*")
+		}
+	}
+
+	return ri
+}
+
+// url returns an URL path generated from the path of the r with the params.
+func (r *Route) url(params Map) (string, error) {
+	buf := &bytes.Buffer{}
+
+	for i, l := 0, len(r.path); i < l; i++ {
+		switch r.path[i] {
+		case ':':
+			pname, pattern, n := splitParam(r.path[i+1:])
+			i += n
+
+			pv, ok := params[pname]
+			if !ok {
+				return "", fmt.Errorf("the param %q of the route %q is missing", pname,
+					r.name)
+			}
+
+			s := fmt.Sprint(pv)
+			if pattern != "" && !r.router.paramConstraint(pattern).match(s) {
+				return "", fmt.Errorf("the param %q of the route %q does not satisfy "+
+					"the constraint <%s>", pname, r.name, pattern)
+			}
+
+			buf.WriteString(escape(s))
+		case '*':
+			pv, ok := params["*"]
+			if !ok {
+				return "", fmt.Errorf("the param \"*\" of the route %q is missing", r.name)
+			}
+
+			ss := strings.Split(fmt.Sprint(pv), "/")
+			for k := range ss {
+				ss[k] = escape(ss[k])
+			}
+
+			buf.WriteString(strings.Join(ss, "/"))
+		default:
+			buf.WriteByte(r.path[i])
+		}
+	}
+
+	return buf.String(), nil
 }
 
 // hasLastSlash reports whether the s has the last '/'.
@@ -382,30 +843,54 @@ func hasLastSlash(s string) bool {
 	return len(s) > 0 && s[len(s)-1] == '/'
 }
 
-// pathWithoutParamNames returns a path from the p without the param names.
+// pathWithoutParamNames returns a path from the p without the param names. The param constraints
+// are kept.
 func pathWithoutParamNames(p string) string {
 	for i, l := 0, len(p); i < l; i++ {
 		if p[i] == ':' {
-			j := i + 1
+			pname, _, n := splitParam(p[i+1:])
+			p = p[:i+1] + p[i+1+len(pname):]
+			i, l = i+n-len(pname), len(p)
+		}
+	}
+	return p
+}
 
-			for ; i < l && p[i] != '/'; i++ {
-			}
+// paramEnd returns the index of the end of the first param in the p.
+func paramEnd(p string) int {
+	i := 0
+	for ; i < len(p) && p[i] != '/'; i++ {
+	}
+	return i
+}
 
-			p = p[:j] + p[i:]
-			i, l = j, len(p)
+// splitParam splits the param at the start of the p, which is the part of a path right after the
+// ':' of the param, into its name and its constraint pattern. The n is the length of the param in
+// the p. The constraint ends at the first '>' that is followed by a '/' or the end of the p, so it
+// can contain the ':', the '*' and the '>'.
+func splitParam(p string) (name, pattern string, n int) {
+	for ; n < len(p) && p[n] != '/' && p[n] != '<'; n++ {
+	}
 
-			if i == l {
-				break
-			}
+	if name = p[:n]; n == len(p) || p[n] == '/' {
+		return name, "", n
+	}
+
+	for i := n + 1; i < len(p); i++ {
+		if p[i] == '>' && (i+1 == len(p) || p[i+1] == '/') {
+			return name, p[n+1 : i], i + 1
 		}
 	}
-	return p
+
+	return name, p[n+1:], len(p)
 }
 
 // pathClean returns a clean path from the p.
 func pathClean(p string) string {
 	if p == "" {
 		return "/"
+	} else if isCleanPath(p) {
+		return p
 	}
 
 	b := make([]byte, 0, len(p))
@@ -429,6 +914,27 @@ func pathClean(p string) string {
 	return *(*string)(unsafe.Pointer(&b))
 }
 
+// isCleanPath reports whether the p is already clean, which means it starts with the "/", has no
+// "//" and does not end with the "/" except the root path.
+func isCleanPath(p string) bool {
+	if p[0] != '/' || len(p) > 1 && p[len(p)-1] == '/' {
+		return false
+	}
+
+	for i := 1; i < len(p); i++ {
+		if p[i] == '/' && p[i-1] == '/' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// escape returns a path segment escaped from the s. It is the reverse of the `unescape()`.
+func escape(s string) string {
+	return strings.Replace(url.PathEscape(s), "+", "%2B", -1)
+}
+
 // unescape return a normal string unescaped from the s.
 func unescape(s string) string {
 	// Count the %, check that they're well-formed.
@@ -530,6 +1036,34 @@ func (n *node) childByLabel(l byte) *node {
 	return nil
 }
 
+// childByPrefix returns a child `node` of the n by the provided prefix pre.
+func (n *node) childByPrefix(pre string) *node {
+	for _, c := range n.children {
+		if c.prefix == pre {
+			return c
+		}
+	}
+	return nil
+}
+
+// paramChild returns a param child `node` of the n whose constraint is satisfied by the pv. Only
+// the children that come after the after are considered if the after is not nil.
+func (n *node) paramChild(pv string, after *node) *node {
+	for _, c := range n.children {
+		if after != nil {
+			if c == after {
+				after = nil
+			}
+			continue
+		}
+
+		if c.kind == paramKind && (c.constraint == nil || c.constraint.match(pv)) {
+			return c
+		}
+	}
+	return nil
+}
+
 // childByKind returns a child `node` of the n by the provided kind t.
 func (n *node) childByKind(t nodeKind) *node {
 	for _, c := range n.children {
@@ -540,46 +1074,207 @@ func (n *node) childByKind(t nodeKind) *node {
 	return nil
 }
 
-// addChild adds the c into the children nodes of the n.
+// addChild adds the c into the children nodes of the n. The constrained param nodes are always in
+// front of the unconstrained ones.
 func (n *node) addChild(c *node) {
+	if c.kind == paramKind && c.constraint != nil {
+		for i, nc := range n.children {
+			if nc.kind == paramKind && nc.constraint == nil {
+				n.children = append(n.children, nil)
+				copy(n.children[i+1:], n.children[i:])
+				n.children[i] = c
+				return
+			}
+		}
+	}
 	n.children = append(n.children, c)
 }
 
-// handler returns a `Handler` by the provided method.
+// handler returns a `Handler` by the provided method. The HEAD falls back to the GET if there is
+// no `Handler` for it.
 func (n *node) handler(method string) Handler {
 	switch method {
 	case GET:
 		return n.methodHandler.get
+	case HEAD:
+		if n.methodHandler.head != nil {
+			return n.methodHandler.head
+		}
+		return n.methodHandler.get
 	case POST:
 		return n.methodHandler.post
 	case PUT:
 		return n.methodHandler.put
+	case PATCH:
+		return n.methodHandler.patch
 	case DELETE:
 		return n.methodHandler.delete
+	case CONNECT:
+		return n.methodHandler.connect
+	case OPTIONS:
+		return n.methodHandler.options
+	case TRACE:
+		return n.methodHandler.trace
 	}
 	return nil
 }
 
 // addHandler adds the h into the filed `methodHandler` of the n with the provided method.
 func (n *node) addHandler(method string, h Handler) {
+	if h == nil {
+		return
+	}
+
 	switch method {
 	case GET:
 		n.methodHandler.get = h
+	case HEAD:
+		n.methodHandler.head = h
 	case POST:
 		n.methodHandler.post = h
 	case PUT:
 		n.methodHandler.put = h
+	case PATCH:
+		n.methodHandler.patch = h
 	case DELETE:
 		n.methodHandler.delete = h
+	case CONNECT:
+		n.methodHandler.connect = h
+	case OPTIONS:
+		n.methodHandler.options = h
+	case TRACE:
+		n.methodHandler.trace = h
 	}
-}
 
-// checkMethodNotAllowed returns a `Handler` by checked methods.
-func (n *node) checkMethodNotAllowed() Handler {
+	n.methodHandler.allowedMethods = n.methodHandler.allowedMethods[:0]
 	for _, m := range methods {
-		if h := n.handler(m); h != nil {
-			return MethodNotAllowedHandler
+		if m == OPTIONS || n.handler(m) != nil {
+			n.methodHandler.allowedMethods = append(n.methodHandler.allowedMethods, m)
 		}
 	}
-	return NotFoundHandler
+}
+
+// caseInsensitivePath returns the path that matches the path case-insensitively in the n and its
+// children. The returned path is in the case of the registered route. It returns nil if there is
+// no route matched.
+func (n *node) caseInsensitivePath(path string, buf []byte) []byte {
+	switch n.kind {
+	case staticKind:
+		if len(path) < len(n.prefix) || !strings.EqualFold(path[:len(n.prefix)], n.prefix) {
+			return nil
+		}
+		buf = append(buf, n.prefix...)
+		path = path[len(n.prefix):]
+	case paramKind:
+		i := paramEnd(path)
+		if i == 0 || n.constraint != nil && !n.constraint.match(unescape(path[:i])) {
+			return nil
+		}
+		buf = append(buf, path[:i]...)
+		path = path[i:]
+	case anyKind:
+		return append(buf, path...)
+	}
+
+	if path == "" && n.methodHandler.allowedMethods != nil {
+		return buf
+	}
+
+	for _, c := range n.children {
+		if b := c.caseInsensitivePath(path, buf); b != nil {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// checkMethodNotAllowed returns a `Handler` by checked methods. The OPTIONS requests will be
+// answered by the `OPTIONSHandler` if there is any other method registered in the n. It returns
+// nil if the method is not allowed or there is no method registered in the n.
+func (n *node) checkMethodNotAllowed(method string) Handler {
+	if method == OPTIONS && n.methodHandler.allowedMethods != nil {
+		return OPTIONSHandler
+	}
+	return nil
+}
+
+// isInt reports whether the s is a decimal integer.
+func isInt(s string) bool {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	return isUint(s)
+}
+
+// isUint reports whether the s is an unsigned decimal integer.
+func isUint(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlpha reports whether the s only has the ASCII letters.
+func isAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlnum reports whether the s only has the ASCII letters and digits.
+func isAlnum(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; (c < 'a' || c > 'z') && (s[i] < '0' || s[i] > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isHex reports whether the s only has the hex chars.
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !ishex(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isUUID reports whether the s is a UUID in the form "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx".
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !ishex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
 }
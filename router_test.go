@@ -37,6 +37,26 @@ func TestRouterCheckPath(t *testing.T) {
 
 	path = "/:foobar*"
 	assert.Panics(t, func() { r.checkPath(path) })
+
+	path = "/foo<int>"
+	assert.Panics(t, func() { r.checkPath(path) })
+
+	path = "/:<int>"
+	assert.Panics(t, func() { r.checkPath(path) })
+
+	path = "/:foo<>"
+	assert.Panics(t, func() { r.checkPath(path) })
+
+	path = "/:foo<[^/]+>"
+	assert.Panics(t, func() { r.checkPath(path) })
+
+	path = "/:foo<[a-z]*>/:bar<[0-9:]+>"
+	assert.NotPanics(t, func() { r.checkPath(path) })
+
+	a.Config.RouterStrictSlash = true
+
+	path = "/foobar/"
+	assert.NotPanics(t, func() { r.checkPath(path) })
 }
 
 func TestRouterCheckRoute(t *testing.T) {
@@ -57,6 +77,32 @@ func TestRouterCheckRoute(t *testing.T) {
 	path = "/:foobar/:foobar"
 
 	assert.Panics(t, func() { r.add(method, path, func(*Context) error { return nil }) })
+
+	path = "/:foo<int>"
+
+	assert.NotPanics(t, func() { r.checkRoute(method, path) })
+
+	a.add(method, path, func(*Context) error { return nil })
+
+	path = "/:bar<int>"
+
+	assert.Panics(t, func() { r.checkRoute(method, path) })
+
+	path = "/:foo<[0-9]{2}:[0-9]{2}>/:bar"
+
+	assert.NotPanics(t, func() { r.checkRoute(method, path) })
+
+	a.add(method, path, func(*Context) error { return nil })
+
+	path = "/:baz<[0-9]{2}:[0-9]{2}>/:qux"
+
+	assert.Panics(t, func() { r.checkRoute(method, path) })
+
+	assert.Equal(t, "/:<[0-9]{2}:[0-9]{2}>/:", pathWithoutParamNames(path))
+
+	path = "/:foo<(>"
+
+	assert.Panics(t, func() { r.add(method, path, func(*Context) error { return nil }) })
 }
 
 func TestRouterMatchStatic(t *testing.T) {
@@ -335,6 +381,101 @@ func TestRouterMatchingPriority(t *testing.T) {
 	assert.Equal(t, "1/followers", c.Param("*"))
 }
 
+func TestRouterMatchParamConstraint(t *testing.T) {
+	a := New()
+	r := a.router
+	h := func(*Context) error { return nil }
+
+	r.add(GET, "/users/:name", h)
+	r.add(GET, "/users/:id<int>", h)
+	r.add(GET, "/users/:id<int>/posts/:slug<[a-z0-9-]+>", h)
+	r.add(GET, "/users/*", h)
+	r.add(GET, "/v/:uuid<uuid>", h)
+
+	c := a.contextPool.Get().(*Context)
+	r.route(GET, "/users/123", c)
+	assert.Equal(t, "/users/:id<int>", c.PristinePath)
+	assert.Equal(t, "123", c.Param("id"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/users/air", c)
+	assert.Equal(t, "/users/:name", c.PristinePath)
+	assert.Equal(t, "air", c.Param("name"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/users/123/posts/hello-world", c)
+	assert.Equal(t, "/users/:id<int>/posts/:slug<[a-z0-9-]+>", c.PristinePath)
+	assert.Equal(t, "123", c.Param("id"))
+	assert.Equal(t, "hello-world", c.Param("slug"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/users/123/posts/Hello_World", c)
+	assert.Equal(t, "/users/*", c.PristinePath)
+	assert.Equal(t, "123/posts/Hello_World", c.Param("*"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/v/6ba7b810-9dad-11d1-80b4-00c04fd430c8", c)
+	assert.Equal(t, "/v/:uuid<uuid>", c.PristinePath)
+	assert.Equal(t, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", c.Param("uuid"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/v/6ba7b810", c)
+	assert.Empty(t, c.PristinePath)
+}
+
+func TestRouterMatchParamConstraintFallThrough(t *testing.T) {
+	a := New()
+	r := a.router
+	h := func(*Context) error { return nil }
+
+	r.add(GET, "/users/:id<int>/posts", h)
+	r.add(GET, "/users/:name/profile", h)
+	r.add(GET, "/users/:name", h)
+	r.add(GET, "/users/:id<int>/files/:file<[a-z]+>", h)
+	r.add(GET, "/users/:name/files/*", h)
+
+	c := a.contextPool.Get().(*Context)
+	r.route(GET, "/users/1/posts", c)
+	assert.Equal(t, "/users/:id<int>/posts", c.PristinePath)
+	assert.Equal(t, "1", c.Param("id"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/users/1/profile", c)
+	assert.Equal(t, "/users/:name/profile", c.PristinePath)
+	assert.Equal(t, "1", c.Param("name"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/users/1", c)
+	assert.Equal(t, "/users/:name", c.PristinePath)
+	assert.Equal(t, "1", c.Param("name"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/users/1/files/air", c)
+	assert.Equal(t, "/users/:id<int>/files/:file<[a-z]+>", c.PristinePath)
+	assert.Equal(t, "air", c.Param("file"))
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/users/1/files/air.go", c)
+	assert.Equal(t, "/users/:name/files/*", c.PristinePath)
+	assert.Equal(t, []string{"name", "*"}, c.ParamNames)
+	assert.Equal(t, []string{"1", "air.go"}, c.ParamValues)
+}
+
+func TestRouterParamConstraintFuncs(t *testing.T) {
+	assert.True(t, isInt("-1"))
+	assert.False(t, isInt("-"))
+	assert.True(t, isUint("2333"))
+	assert.False(t, isUint("23a"))
+	assert.True(t, isAlpha("Air"))
+	assert.False(t, isAlpha("Air2"))
+	assert.True(t, isAlnum("Air2"))
+	assert.False(t, isAlnum("Air-2"))
+	assert.True(t, isHex("fF09"))
+	assert.False(t, isHex("fg"))
+	assert.True(t, isUUID("6BA7B810-9DAD-11D1-80B4-00C04FD430C8"))
+	assert.False(t, isUUID("6ba7b810_9dad_11d1_80b4_00c04fd430c8"))
+}
+
 func TestRouterMatchMethodNotAllowed(t *testing.T) {
 	a := New()
 	a.server = newServer(a)
@@ -348,11 +489,308 @@ func TestRouterMatchMethodNotAllowed(t *testing.T) {
 
 	a.server.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", rec.Header().Get(HeaderAllow))
+
+	r.add(PUT, path, func(*Context) error { return nil })
+
+	req, _ = http.NewRequest("PROPFIND", path, nil)
+	rec = httptest.NewRecorder()
+
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD, PUT, OPTIONS", rec.Header().Get(HeaderAllow))
+}
+
+func TestRouterAllowedMethods(t *testing.T) {
+	a := New()
+	r := a.router
+
+	r.add(GET, "/users/:id", func(*Context) error { return nil })
+	r.add(DELETE, "/users/:id", func(*Context) error { return nil })
+
+	c := a.contextPool.Get().(*Context)
+	r.route(GET, "/users/1", c)
+	assert.Equal(t, []string{GET, HEAD, DELETE, OPTIONS}, c.AllowedMethods())
+
+	c = a.contextPool.Get().(*Context)
+	r.route(GET, "/posts/1", c)
+	assert.Nil(t, c.AllowedMethods())
+}
+
+func TestRouterRedirectNonCanonicalPath(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+	a.Config.RouterRedirectCode = http.StatusPermanentRedirect
+
+	a.GET("/users/:id", func(c *Context) error { return c.String(c.Param("id")) })
+
+	cases := []struct {
+		target   string
+		code     int
+		location string
+	}{
+		{"/users/1", http.StatusOK, ""},
+		{"/users/1/", http.StatusPermanentRedirect, "/users/1"},
+		{"/users//1?foo=bar", http.StatusPermanentRedirect, "/users/1?foo=bar"},
+		{"/Users/1", http.StatusNotFound, ""},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(GET, c.target, nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, c.code, rec.Code, c.target)
+		assert.Equal(t, c.location, rec.Header().Get(HeaderLocation), c.target)
+	}
+
+	a.Config.RouterRedirectCode = 0
+
+	req, _ := http.NewRequest(GET, "/users/1/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Body.String())
+
+	a.Config.RouterRedirectCode = http.StatusOK
+
+	req, _ = http.NewRequest(GET, "/users/1/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/users/1", rec.Header().Get(HeaderLocation))
+}
+
+func TestRouterStrictSlash(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+	a.Config.RouterStrictSlash = true
+
+	a.GET("/a", func(c *Context) error { return c.String("a") })
+	a.GET("/a/", func(c *Context) error { return c.String("a/") })
+	a.GET("/b/", func(c *Context) error { return c.String("b/") })
+	a.GET("/files/*", func(c *Context) error { return c.String(c.Param("*")) })
+
+	cases := []struct {
+		target   string
+		code     int
+		body     string
+		location string
+	}{
+		{"/a", http.StatusOK, "a", ""},
+		{"/a/", http.StatusOK, "a/", ""},
+		{"/b/", http.StatusOK, "b/", ""},
+		{"/b", http.StatusNotFound, "", ""},
+		{"/files/foo/", http.StatusOK, "foo/", ""},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(GET, c.target, nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, c.code, rec.Code, c.target)
+		if c.body != "" {
+			assert.Equal(t, c.body, rec.Body.String(), c.target)
+		}
+	}
+
+	a.Config.RouterRedirectCode = http.StatusMovedPermanently
+
+	req, _ := http.NewRequest(GET, "/b", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/b/", rec.Header().Get(HeaderLocation))
+
+	req, _ = http.NewRequest(GET, "/a//", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/a/", rec.Header().Get(HeaderLocation))
+}
+
+func TestRouterCaseInsensitive(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+	a.Config.RouterCaseInsensitive = true
+
+	a.GET("/Users/:id<int>/Posts", func(c *Context) error { return c.String(c.Param("id")) })
+	a.GET("/Assets/*", func(c *Context) error { return c.String(c.Param("*")) })
+
+	req, _ := http.NewRequest(GET, "/users/1/posts", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Body.String())
+
+	req, _ = http.NewRequest(GET, "/users/foo/posts", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	a.Config.RouterRedirectCode = http.StatusMovedPermanently
+
+	req, _ = http.NewRequest(GET, "/USERS/1/posts/?foo=bar", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/Users/1/Posts?foo=bar", rec.Header().Get(HeaderLocation))
+
+	req, _ = http.NewRequest(GET, "/assets/CSS/main.css", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/Assets/CSS/main.css", rec.Header().Get(HeaderLocation))
+}
+
+func TestRouterNextRoute(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+
+	pages := map[string]string{"about": "about page"}
+
+	a.GET("/:slug", func(c *Context) error {
+		if p, ok := pages[c.Param("slug")]; ok {
+			return c.String(p)
+		}
+		return ErrNextRoute
+	})
+	a.GET("/*", func(c *Context) error {
+		if c.Param("*") == "main.css" {
+			return c.String("file " + c.Param("*"))
+		}
+		return ErrNextRoute
+	})
+	a.GET("/users/:id", func(c *Context) error { return ErrNextRoute })
+	a.GET("/users/:name<alpha>", func(c *Context) error {
+		return c.String("user " + c.Param("name"))
+	}, WrapGas(func(c *Context) error { return ErrNextRoute }))
+
+	cases := []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/about", http.StatusOK, "about page"},
+		{"/main.css", http.StatusOK, "file main.css"},
+		{"/foo", http.StatusNotFound, ""},
+		{"/users/1", http.StatusNotFound, ""},
+		{"/users/foo", http.StatusNotFound, ""},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(GET, c.target, nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, c.code, rec.Code, c.target)
+		if c.body != "" {
+			assert.Equal(t, c.body, rec.Body.String(), c.target)
+		}
+	}
+}
+
+func TestRouterNextRouteParamSiblings(t *testing.T) {
+	a := New()
+	a.server = newServer(a)
+
+	a.GET("/users/:name<alpha>", func(c *Context) error { return ErrNextRoute })
+	a.GET("/users/:id", func(c *Context) error {
+		return c.String("id " + c.Param("id"))
+	})
+	a.GET("/files/:name<[a-z]+>/:version<int>", func(c *Context) error {
+		return ErrNextRoute
+	})
+	a.GET("/files/:name/:version", func(c *Context) error { return ErrNextRoute })
+	a.GET("/files/*", func(c *Context) error {
+		return c.String("file " + c.Param("*"))
+	})
+
+	cases := []struct {
+		target string
+		body   string
+	}{
+		{"/users/bob", "id bob"},
+		{"/users/1", "id 1"},
+		{"/files/air/1", "file air/1"},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(GET, c.target, nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, c.target)
+		assert.Equal(t, c.body, rec.Body.String(), c.target)
+	}
+}
+
+func TestRouterNextRouteParamValues(t *testing.T) {
+	a := New()
+	r := a.router
+
+	r.add(GET, "/:a/b", func(c *Context) error { return ErrNextRoute })
+	r.add(GET, "/:a/:b", func(c *Context) error { return ErrNextRoute })
+	r.add(GET, "/:a/*", func(c *Context) error { return nil })
+
+	c := a.contextPool.Get().(*Context)
+	r.route(GET, "/x/b", c)
+	assert.Equal(t, []string{"a"}, c.ParamNames)
+	assert.Equal(t, []string{"x"}, c.ParamValues)
+
+	assert.True(t, r.next(c))
+	assert.Equal(t, []string{"a", "b"}, c.ParamNames)
+	assert.Equal(t, []string{"x", "b"}, c.ParamValues)
+
+	assert.True(t, r.next(c))
+	assert.Equal(t, []string{"a", "*"}, c.ParamNames)
+	assert.Equal(t, []string{"x", "b"}, c.ParamValues)
+
+	assert.False(t, r.next(c))
+}
+
+func TestRouteInfoParamNames(t *testing.T) {
+	a := New()
+	h := func(*Context) error { return nil }
+
+	ri := a.GET("/:foo<[a-z]*>/x", h).info()
+	assert.Equal(t, []string{"foo"}, ri.ParamNames)
+
+	ri = a.GET("/times/:time<[0-9]{2}:[0-9]{2}>/:tz", h).info()
+	assert.Equal(t, []string{"time", "tz"}, ri.ParamNames)
+
+	ri = a.GET("/files/:name<(?P<n>[a-z]+)>/*", h).info()
+	assert.Equal(t, []string{"name", "*"}, ri.ParamNames)
+}
+
+func TestRouterSplitParam(t *testing.T) {
+	name, pattern, n := splitParam("id")
+	assert.Equal(t, "id", name)
+	assert.Empty(t, pattern)
+	assert.Equal(t, 2, n)
+
+	name, pattern, n = splitParam("id/posts")
+	assert.Equal(t, "id", name)
+	assert.Empty(t, pattern)
+	assert.Equal(t, 2, n)
+
+	name, pattern, n = splitParam("time<[0-9]{2}:[0-9]{2}>/x")
+	assert.Equal(t, "time", name)
+	assert.Equal(t, "[0-9]{2}:[0-9]{2}", pattern)
+	assert.Equal(t, 23, n)
+
+	name, pattern, n = splitParam("path<[a-z/]+>")
+	assert.Equal(t, "path", name)
+	assert.Equal(t, "[a-z/]+", pattern)
+	assert.Equal(t, 13, n)
 }
 
 func TestRouterPathClean(t *testing.T) {
 	assert.Equal(t, "/", pathClean(""))
 	assert.Equal(t, "/users", pathClean("users"))
+	assert.Equal(t, "/", pathClean("/"))
+	assert.Equal(t, "/users/1", pathClean("/users/1"))
+	assert.Equal(t, "/users/1", pathClean("//users//1/"))
+	assert.True(t, isCleanPath("/users/1"))
+	assert.False(t, isCleanPath("/users/1/"))
+	assert.False(t, isCleanPath("/users//1"))
 }
 
 func TestRouterUnescape(t *testing.T) {
@@ -1,6 +1,18 @@
 package air
 
-import "net/http"
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
 
 // server represents the HTTP server.
 //
@@ -8,62 +20,214 @@ import "net/http"
 type server struct {
 	*http.Server
 
-	air *Air
+	air       *Air
+	handler   Handler
+	httpsPort string
+
+	listeners      []net.Listener
+	listenersMutex sync.Mutex
+	upgradeReady   *os.File
+
+	webSockets       map[*WebSocketConn]struct{}
+	webSocketsMutex  sync.Mutex
+	webSocketsClosed bool
+
+	shuttingDown int32
+	shutdownOnce sync.Once
+	shutdownDone chan struct{}
 }
 
 // newServer returns a pointer of a new instance of the `server`.
 func newServer(a *Air) *server {
-	return &server{
-		Server: &http.Server{},
-		air:    a,
+	s := &server{
+		Server:       &http.Server{},
+		air:          a,
+		shutdownDone: make(chan struct{}),
 	}
+	s.compose()
+	return s
 }
 
-// serve starts the HTTP server.
-func (s *server) serve() error {
+// compose composes the pregases, the router and the gases of the `Air` instance of the s into the
+// chain that performed for every HTTP request. It should be called again once the gases have been
+// changed.
+func (s *server) compose() {
+	// Gases
+	gh := Handler(routeHandler)
+	for i := len(s.air.gases) - 1; i >= 0; i-- {
+		gh = s.air.gases[i](gh)
+	}
+
+	h := func(c *Context) error {
+		if h := s.air.matchHost(c); h != nil {
+			h.router.route(c.Request.Method, c.Request.URL.EscapedPath(), c)
+		} else if s.air.UnknownHostHandler != nil && len(s.air.hosts) > 0 {
+			c.Handler = s.air.UnknownHostHandler
+		} else if !s.air.routeVersion(c) {
+			s.air.router.route(c.Request.Method, c.Request.URL.EscapedPath(), c)
+		}
+
+		return gh(c)
+	}
+
+	// Pregases
+	for i := len(s.air.pregases) - 1; i >= 0; i-- {
+		h = s.air.pregases[i](h)
+	}
+
+	moh := MethodOverrideGas(h)
+
+	s.handler = func(c *Context) error {
+		if s.air.Config.MethodOverrideEnabled {
+			return moh(c)
+		}
+		return h(c)
+	}
+}
+
+// serve starts the HTTP server. It accepts the HTTP connections on the l, or on the listeners
+// returned by the `listen()` if the l is nil. It returns once all the listeners stop serving, and
+// closes the s if any of them fails.
+func (s *server) serve(l net.Listener) error {
 	c := s.air.Config
 
 	s.Addr = c.Address
 	s.Handler = s
 	s.ReadTimeout = c.ReadTimeout
+	s.ReadHeaderTimeout = c.ReadHeaderTimeout
 	s.WriteTimeout = c.WriteTimeout
+	s.IdleTimeout = c.IdleTimeout
 	s.MaxHeaderBytes = c.MaxHeaderBytes
+	s.SetKeepAlivesEnabled(c.KeepAliveEnabled)
+	s.ConnContext = connContext
+
+	if c.H2CEnabled {
+		// The `http2.ConfigureServer()` makes the h2c connections
+		// hijacked from the s be gracefully shut down with the s.
+		h2s := &http2.Server{}
+		if err := http2.ConfigureServer(s.Server, h2s); err != nil {
+			return err
+		}
 
-	if c.TLSCertFile != "" && c.TLSKeyFile != "" {
-		return s.ListenAndServeTLS(c.TLSCertFile, c.TLSKeyFile)
+		s.Handler = h2c.NewHandler(s, h2s)
 	}
 
-	return s.ListenAndServe()
-}
+	var (
+		ls  []net.Listener
+		lcs []ListenerConfig
+	)
 
-// ServeHTTP implements the `http.Handler`.
-func (s *server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	c := s.air.contextPool.Get().(*Context)
-	c.feed(req, rw)
+	if l != nil {
+		ls = []net.Listener{l}
+		lcs = []ListenerConfig{s.defaultListenerConfig()}
+	} else {
+		var err error
+		if ls, lcs, err = s.listen(); err != nil {
+			return err
+		}
+	}
 
-	// Gases
-	h := func(c *Context) error {
-		if methodAllowed(c.Request.Method) {
-			s.air.router.route(c.Request.Method, c.Request.URL.EscapedPath(), c)
-		} else {
-			c.Handler = MethodNotAllowedHandler
+	s.listenersMutex.Lock()
+	s.listeners = append([]net.Listener(nil), ls...)
+	s.listenersMutex.Unlock()
+
+	if c.ProxyProtocolEnabled {
+		timeout := c.ReadHeaderTimeout
+		if timeout == 0 {
+			timeout = c.ReadTimeout
 		}
 
-		h := c.Handler
-		for i := len(s.air.gases) - 1; i >= 0; i-- {
-			h = s.air.gases[i](h)
+		for i := range ls {
+			ppl, err := newProxyProtocolListener(
+				ls[i],
+				c.ProxyProtocolTrustedCIDRs,
+				timeout,
+			)
+			if err != nil {
+				for _, l := range ls {
+					l.Close()
+				}
+				return err
+			}
+
+			ls[i] = ppl
 		}
+	}
 
-		return h(c)
+	tlsConfigs, tc, err := s.tlsConfigs(lcs)
+	if err != nil {
+		for _, l := range ls {
+			l.Close()
+		}
+		return err
+	} else if tc != nil {
+		defer tc.Close()
 	}
 
-	// Pregases
-	for i := len(s.air.pregases) - 1; i >= 0; i-- {
-		h = s.air.pregases[i](h)
+	if c.MaxConnections > 0 {
+		slots := make(chan struct{}, c.MaxConnections)
+		for i := range ls {
+			ls[i] = newLimitListener(s.air, ls[i], slots)
+		}
+	}
+
+	s.httpsPort = httpsPort(lcs)
+
+	atomic.StoreInt32(&s.air.ready, 1)
+	defer atomic.StoreInt32(&s.air.ready, 0)
+
+	if s.upgradeReady != nil {
+		notifyUpgradeReady(s.upgradeReady)
+		s.upgradeReady = nil
+	}
+
+	errs := make(chan error, len(ls))
+	for i := range ls {
+		go func(l net.Listener, lc ListenerConfig, tlsConfig *tls.Config) {
+			errs <- s.serveListener(l, lc, tlsConfig)
+		}(ls[i], lcs[i], tlsConfigs[i])
+	}
+
+	err = http.ErrServerClosed
+	for range ls {
+		if e := <-errs; e != http.ErrServerClosed && err == http.ErrServerClosed {
+			err = e
+			s.Close()
+		}
+	}
+
+	return err
+}
+
+// serveListener accepts the HTTP connections on the l with the lc. The connections will be
+// served over the TLS with the tlsConfig if it is non-nil.
+func (s *server) serveListener(
+	l net.Listener,
+	lc ListenerConfig,
+	tlsConfig *tls.Config,
+) error {
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	} else if lc.HTTPSRedirect {
+		l = httpsRedirectListener{l}
+	}
+
+	return s.Serve(l)
+}
+
+// ServeHTTP implements the `http.Handler`.
+func (s *server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Context().Value(httpsRedirectContextKey{}) != nil &&
+		!strings.HasPrefix(req.URL.Path, "/.well-known/") {
+		s.redirectToHTTPS(rw, req)
+		return
 	}
 
+	c := s.air.contextPool.Get().(*Context)
+	c.feed(req, rw)
+
 	// Execute chain
-	if err := h(c); err != nil {
+	if err := s.handler(c); err != nil {
 		s.air.HTTPErrorHandler(err, c)
 	}
 
@@ -71,6 +235,55 @@ func (s *server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	s.air.contextPool.Put(c)
 }
 
+// redirectToHTTPS redirects the req to the HTTPS origin with the 308 status code.
+func (s *server) redirectToHTTPS(rw http.ResponseWriter, req *http.Request) {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else {
+		host = strings.Trim(host, "[]")
+	}
+
+	if s.httpsPort != "443" {
+		host = net.JoinHostPort(host, s.httpsPort)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	http.Redirect(
+		rw,
+		req,
+		"https://"+host+req.URL.RequestURI(),
+		http.StatusPermanentRedirect,
+	)
+}
+
+// httpsRedirectContextKey is the key of the value in the context of the HTTP requests that need to
+// be redirected to the HTTPS origin.
+type httpsRedirectContextKey struct{}
+
+// connContext marks the context of the connections accepted by the `httpsRedirectListener` with
+// the `httpsRedirectContextKey`.
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(httpsRedirectConn); ok {
+		return context.WithValue(ctx, httpsRedirectContextKey{}, true)
+	}
+	return ctx
+}
+
+// routeHandler calls the `Context#Handler` of the c. The c will be routed to the next matched
+// route and call its `Context#Handler` again if the `ErrNextRoute` is returned.
+func routeHandler(c *Context) error {
+	err := c.Handler(c)
+	for err == ErrNextRoute && c.routeState.router != nil {
+		if !c.routeState.router.next(c) {
+			return c.Handler(c)
+		}
+		err = c.Handler(c)
+	}
+	return err
+}
+
 // methodAllowed reports whether the method is allowed.
 func methodAllowed(method string) bool {
 	for _, m := range methods {
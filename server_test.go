@@ -1,11 +1,19 @@
 package air
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
 )
 
 func TestServerMethodAllowed(t *testing.T) {
@@ -17,11 +25,9 @@ func TestServerMethodAllowed(t *testing.T) {
 	}
 
 	others := []string{
-		"HEAD",
-		"PATCH",
-		"CONNECT",
-		"OPTIONS",
-		"TRACE",
+		"PROPFIND",
+		"MKCOL",
+		"LOCK",
 	}
 
 	for _, m := range others {
@@ -31,3 +37,139 @@ func TestServerMethodAllowed(t *testing.T) {
 		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
 	}
 }
+
+func TestServerCompose(t *testing.T) {
+	a := New()
+
+	composed := 0
+	gas := func(next Handler) Handler {
+		composed++
+		return next
+	}
+
+	a.Contain(gas)
+	a.GET("/", func(c *Context) error { return c.String("index") }, gas)
+	assert.Equal(t, 2, composed)
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(GET, "/", nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, "index", rec.Body.String())
+	}
+
+	assert.Equal(t, 2, composed)
+}
+
+func TestServerH2C(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.H2CEnabled = true
+
+	proceed := make(chan struct{})
+	a.GET("/stream", func(c *Context) error {
+		c.Response.WriteHeader(http.StatusOK)
+		if c.Response.Pusher != nil {
+			c.Response.Write([]byte("pusher;"))
+		}
+		c.Response.Write([]byte(c.Request.Proto + ";"))
+		c.Response.Flush()
+		<-proceed
+		_, err := c.Response.Write([]byte("done"))
+		return err
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(
+				ctx context.Context,
+				network string,
+				address string,
+				_ *tls.Config,
+			) (net.Conn, error) {
+				return net.Dial(network, address)
+			},
+		},
+	}
+
+	res, err := client.Get("http://" + a.Config.Address + "/stream")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, res.ProtoMajor)
+
+	b := make([]byte, len("pusher;HTTP/2.0;"))
+	_, err = io.ReadFull(res.Body, b)
+	assert.NoError(t, err)
+	assert.Equal(t, "pusher;HTTP/2.0;", string(b))
+
+	close(proceed)
+	b, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "done", string(b))
+
+	conn, err := net.Dial("tcp", a.Config.Address)
+	assert.NoError(t, err)
+	conn.Write([]byte("GET /stream HTTP/1.1\r\n" +
+		"Host: " + a.Config.Address + "\r\n" +
+		"Connection: Upgrade, HTTP2-Settings\r\n" +
+		"Upgrade: h2c\r\n" +
+		"HTTP2-Settings: AAMAAABkAAQAAP__\r\n\r\n"))
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	assert.True(t, strings.HasPrefix(line, "HTTP/1.1 101"), line)
+	conn.Close()
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+}
+
+// benchmarkResponseWriter is an `http.ResponseWriter` that discards everything.
+type benchmarkResponseWriter struct {
+	header http.Header
+}
+
+func (rw *benchmarkResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *benchmarkResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (rw *benchmarkResponseWriter) WriteHeader(int) {}
+
+func benchmarkServerServeHTTP(b *testing.B, path, target string) {
+	a := New()
+	a.Contain(func(next Handler) Handler { return next })
+	a.GET(path, func(c *Context) error {
+		return nil
+	}, func(next Handler) Handler { return next })
+
+	req, _ := http.NewRequest(GET, target, nil)
+	rw := &benchmarkResponseWriter{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		a.server.ServeHTTP(rw, req)
+	}
+}
+
+func BenchmarkServerServeHTTPStatic(b *testing.B) {
+	benchmarkServerServeHTTP(b, "/users/profile", "/users/profile")
+}
+
+func BenchmarkServerServeHTTPParam(b *testing.B) {
+	benchmarkServerServeHTTP(b, "/users/:id/posts/:pid", "/users/1/posts/2")
+}
+
+func BenchmarkServerServeHTTPAny(b *testing.B) {
+	benchmarkServerServeHTTP(b, "/assets/*", "/assets/css/main.css")
+}
@@ -0,0 +1,385 @@
+package air
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// tlsCertificates is a set of the TLS certificates loaded from the pairs of the TLS certificate
+// file and the TLS key file. They will be reloaded atomically once the files are changed.
+type tlsCertificates struct {
+	air *Air
+
+	pairs        []TLSCertificateConfig
+	certificates atomic.Value // []*tls.Certificate
+	watcher      *fsnotify.Watcher
+	watcherDone  chan struct{}
+}
+
+// newTLSCertificates returns a pointer of a new instance of the `tlsCertificates` loaded from the
+// pairs. It starts watching the directories of the files of the pairs.
+func newTLSCertificates(a *Air, pairs []TLSCertificateConfig) (*tlsCertificates, error) {
+	tc := &tlsCertificates{
+		air:   a,
+		pairs: pairs,
+	}
+
+	if err := tc.load(); err != nil {
+		return nil, err
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := map[string]bool{}
+	for _, p := range pairs {
+		for _, f := range []string{p.CertFile, p.KeyFile} {
+			dir := filepath.Dir(f)
+			if dirs[dir] {
+				continue
+			}
+
+			if err := w.Add(dir); err != nil {
+				w.Close()
+				return nil, err
+			}
+
+			dirs[dir] = true
+		}
+	}
+
+	tc.watcher = w
+	tc.watcherDone = make(chan struct{})
+
+	go tc.watch()
+
+	return tc, nil
+}
+
+// index returns the index of the pair of the certFile and the keyFile in the tc. It returns -1 if
+// the pair is not found.
+func (tc *tlsCertificates) index(certFile, keyFile string) int {
+	for i, p := range tc.pairs {
+		if p.CertFile == certFile && p.KeyFile == keyFile {
+			return i
+		}
+	}
+	return -1
+}
+
+// load loads all the pairs of the tc. The loaded TLS certificates will be stored only if all of
+// the pairs are loaded successfully.
+func (tc *tlsCertificates) load() error {
+	certificates := make([]*tls.Certificate, 0, len(tc.pairs))
+	for _, p := range tc.pairs {
+		c, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return err
+		}
+
+		if c.Leaf == nil {
+			if c.Leaf, err = x509.ParseCertificate(c.Certificate[0]); err != nil {
+				return err
+			}
+		}
+
+		certificates = append(certificates, &c)
+	}
+
+	tc.certificates.Store(certificates)
+
+	return nil
+}
+
+// watch watchs the changing of the files of the pairs of the tc until the `Close()` is called. All
+// the pairs will be reloaded once any of the files is changed.
+func (tc *tlsCertificates) watch() {
+	defer close(tc.watcherDone)
+	for {
+		select {
+		case event, ok := <-tc.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|
+				fsnotify.Remove) == 0 || !tc.isPairFile(event.Name) {
+				continue
+			}
+
+			if err := tc.load(); err != nil {
+				tc.air.Logger.Errorf("failed to reload the TLS certificates: %v", err)
+			} else {
+				tc.air.Logger.Info("reloaded the TLS certificates")
+			}
+		case err, ok := <-tc.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			tc.air.Logger.Error(err)
+		}
+	}
+}
+
+// isPairFile reports whether the name is one of the files of the pairs of the tc, or is on the
+// path of the symlink target of any of them, which is replaced when the files are swapped
+// atomically through a symlinked directory.
+func (tc *tlsCertificates) isPairFile(name string) bool {
+	name = filepath.Clean(name)
+	for _, p := range tc.pairs {
+		for _, f := range []string{p.CertFile, p.KeyFile} {
+			if filepath.Clean(f) == name {
+				return true
+			}
+
+			t, err := os.Readlink(f)
+			if err != nil {
+				continue
+			} else if !filepath.IsAbs(t) {
+				t = filepath.Join(filepath.Dir(f), t)
+			}
+
+			if t = filepath.Clean(t); t == name ||
+				strings.HasPrefix(t, name+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Close implements the `io.Closer`. It stops watching the files of the pairs of the tc.
+func (tc *tlsCertificates) Close() error {
+	err := tc.watcher.Close()
+	<-tc.watcherDone
+	return err
+}
+
+// getCertificate returns an implementation of the `tls.Config#GetCertificate` that chooses the TLS
+// certificate by the server name indicated by the TLS client. The TLS certificate at the index i
+// is preferred, and will be returned if no TLS certificate matches the server name.
+func (tc *tlsCertificates) getCertificate(
+	i int,
+) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		certificates := tc.certificates.Load().([]*tls.Certificate)
+		if chi.ServerName == "" ||
+			certificates[i].Leaf.VerifyHostname(chi.ServerName) == nil {
+			return certificates[i], nil
+		}
+
+		for _, c := range certificates {
+			if c.Leaf.VerifyHostname(chi.ServerName) == nil {
+				return c, nil
+			}
+		}
+
+		return certificates[i], nil
+	}
+}
+
+// tlsConfigs returns the TLS configs of the listeners of the lcs, which are nil for the ones
+// without the TLS settings, and the `tlsCertificates` shared by them. The `tlsCertificates` is
+// nil if none of the lcs has the TLS settings.
+func (s *server) tlsConfigs(
+	lcs []ListenerConfig,
+) ([]*tls.Config, *tlsCertificates, error) {
+	c := s.air.Config
+
+	tlsConfigs := make([]*tls.Config, len(lcs))
+	pairs := append([]TLSCertificateConfig(nil), c.TLSCertificates...)
+	for _, lc := range lcs {
+		if lc.TLSCertFile == "" || lc.TLSKeyFile == "" {
+			continue
+		}
+
+		pair := TLSCertificateConfig{
+			CertFile: lc.TLSCertFile,
+			KeyFile:  lc.TLSKeyFile,
+		}
+
+		found := false
+		for _, p := range pairs {
+			if p == pair {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	if !hasTLS(lcs) {
+		return tlsConfigs, nil, nil
+	}
+
+	minVersion, err := tlsVersion(c.TLSMinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cipherSuites, err := tlsCipherSuites(c.TLSCipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientAuth, err := tlsClientAuth(c.TLSClientAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var clientCAs *x509.CertPool
+	if c.TLSClientCAFile != "" {
+		b, err := ioutil.ReadFile(c.TLSClientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(b) {
+			return nil, nil, fmt.Errorf(
+				"no certificates found in the TLS client CA file %q",
+				c.TLSClientCAFile,
+			)
+		}
+	} else if clientAuth >= tls.VerifyClientCertIfGiven {
+		return nil, nil, errors.New("the TLS client CA file is required to " +
+			"verify the TLS client certificates")
+	}
+
+	tc, err := newTLSCertificates(s.air, pairs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, lc := range lcs {
+		if lc.TLSCertFile == "" || lc.TLSKeyFile == "" {
+			continue
+		}
+
+		tlsConfigs[i] = &tls.Config{
+			MinVersion:   minVersion,
+			CipherSuites: cipherSuites,
+			ClientAuth:   clientAuth,
+			ClientCAs:    clientCAs,
+			NextProtos:   []string{"h2", "http/1.1"},
+			GetCertificate: tc.getCertificate(
+				tc.index(lc.TLSCertFile, lc.TLSKeyFile),
+			),
+		}
+	}
+
+	return tlsConfigs, tc, nil
+}
+
+// hasTLS reports whether any of the lcs has the TLS settings.
+func hasTLS(lcs []ListenerConfig) bool {
+	for _, lc := range lcs {
+		if lc.TLSCertFile != "" && lc.TLSKeyFile != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsVersions is the TLS versions that can be used in the `Config#TLSMinVersion`.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVersion returns the TLS version of the name. It returns 0 if the name is empty.
+func tlsVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	} else if v, ok := tlsVersions[name]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("the TLS version %q is invalid", name)
+}
+
+// tlsClientAuthTypes is the TLS client authentication policies that can be used in the
+// `Config#TLSClientAuth`.
+var tlsClientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require_any":        tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// tlsClientAuth returns the TLS client authentication policy of the name. It returns the
+// `tls.NoClientCert` if the name is empty.
+func tlsClientAuth(name string) (tls.ClientAuthType, error) {
+	if name == "" {
+		return tls.NoClientCert, nil
+	} else if ca, ok := tlsClientAuthTypes[name]; ok {
+		return ca, nil
+	}
+	return 0, fmt.Errorf("the TLS client auth %q is invalid", name)
+}
+
+// tlsCipherSuiteIDs is the TLS cipher suites that can be used in the `Config#TLSCipherSuites`.
+var tlsCipherSuiteIDs = map[string]uint16{
+	"TLS_RSA_WITH_RC4_128_SHA":                tls.TLS_RSA_WITH_RC4_128_SHA,
+	"TLS_RSA_WITH_3DES_EDE_CBC_SHA":           tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA256":         tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_RC4_128_SHA":        tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_RC4_128_SHA":          tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+	"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA":     tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":    tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":  tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	"TLS_AES_128_GCM_SHA256":                  tls.TLS_AES_128_GCM_SHA256,
+	"TLS_AES_256_GCM_SHA384":                  tls.TLS_AES_256_GCM_SHA384,
+	"TLS_CHACHA20_POLY1305_SHA256":            tls.TLS_CHACHA20_POLY1305_SHA256,
+}
+
+// tlsCipherSuites returns the IDs of the TLS cipher suites of the names. It returns nil if the
+// names is empty.
+func tlsCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := tlsCipherSuiteIDs[name]
+		if !ok {
+			return nil, fmt.Errorf("the TLS cipher suite %q is invalid", name)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
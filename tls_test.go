@@ -0,0 +1,278 @@
+package air
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirServeTLSCertificates(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	fooCertFile, fooKeyFile := writeCertificate(dir, "foo.example.com")
+	barCertFile, barKeyFile := writeCertificate(dir, "bar.example.com")
+
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.TLSCertificates = []TLSCertificateConfig{
+		{CertFile: fooCertFile, KeyFile: fooKeyFile},
+		{CertFile: barCertFile, KeyFile: barKeyFile},
+	}
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	for sn, cn := range map[string]string{
+		"":                "foo.example.com",
+		"foo.example.com": "foo.example.com",
+		"bar.example.com": "bar.example.com",
+		"example.com":     "foo.example.com",
+	} {
+		conn, err := tls.Dial("tcp", a.Config.Address, &tls.Config{
+			ServerName:         sn,
+			NextProtos:         []string{"h2"},
+			InsecureSkipVerify: true,
+		})
+		assert.NoError(t, err)
+		if err != nil {
+			continue
+		}
+
+		cs := conn.ConnectionState()
+		assert.Equal(t, cn, cs.PeerCertificates[0].Subject.CommonName, sn)
+		assert.Equal(t, "h2", cs.NegotiatedProtocol)
+		conn.Close()
+	}
+
+	_, err := tls.Dial("tcp", a.Config.Address, &tls.Config{
+		MaxVersion:         tls.VersionTLS11,
+		InsecureSkipVerify: true,
+	})
+	assert.Error(t, err)
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+}
+
+func TestAirServeTLSConfigError(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCertificate(dir, "localhost")
+
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.TLSCertFile = certFile
+	a.Config.TLSKeyFile = keyFile
+	a.Config.TLSMinVersion = "2.0"
+	assert.Error(t, a.Serve())
+
+	a.Config.TLSMinVersion = "1.2"
+	a.Config.TLSKeyFile = certFile
+	assert.Error(t, a.Serve())
+}
+
+func TestAirServeMutualTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCertificate(dir, "localhost")
+	fooCertFile, fooKeyFile := writeCertificate(dir, "foo.example.com")
+	barCertFile, barKeyFile := writeCertificate(dir, "bar.example.com")
+
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.TLSCertFile = certFile
+	a.Config.TLSKeyFile = keyFile
+	a.Config.TLSClientCAFile = fooCertFile
+	a.Config.TLSClientAuth = "verify_if_given"
+
+	g := NewGroup(a, "/internal", ClientIdentityGas("foo.example.com"))
+	g.GET("", func(c *Context) error {
+		return c.String(strings.Join(c.PeerIdentities(), ","))
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	get := func(certFile, keyFile string) (int, string) {
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+		if certFile != "" {
+			c, _ := tls.LoadX509KeyPair(certFile, keyFile)
+			tlsConfig.GetClientCertificate = func(
+				*tls.CertificateRequestInfo,
+			) (*tls.Certificate, error) {
+				return &c, nil
+			}
+		}
+
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		}
+
+		res, err := client.Get("https://" + a.Config.Address + "/internal")
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer res.Body.Close()
+
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+
+	code, body := get(fooCertFile, fooKeyFile)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "foo.example.com,foo.example.com,127.0.0.1", body)
+
+	code, _ = get("", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = get(barCertFile, barKeyFile)
+	assert.Equal(t, 0, code)
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+}
+
+func TestAirServeMutualTLSConfigError(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCertificate(dir, "localhost")
+
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.TLSCertFile = certFile
+	a.Config.TLSKeyFile = keyFile
+
+	a.Config.TLSClientAuth = "foobar"
+	assert.Error(t, a.Serve())
+
+	a.Config.TLSClientAuth = "require_and_verify"
+	assert.Error(t, a.Serve())
+
+	a.Config.TLSClientCAFile = keyFile
+	assert.Error(t, a.Serve())
+}
+
+func TestTLSCertificatesReload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCertificate(dir, "localhost")
+
+	tc, err := newTLSCertificates(New(), []TLSCertificateConfig{
+		{CertFile: certFile, KeyFile: keyFile},
+	})
+	assert.NoError(t, err)
+	defer tc.Close()
+
+	getCertificate := tc.getCertificate(tc.index(certFile, keyFile))
+
+	old, _ := getCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+	assert.NotNil(t, old)
+
+	writeCertificate(dir, "localhost")
+
+	for i := 0; i < 200; i++ {
+		c, _ := getCertificate(&tls.ClientHelloInfo{})
+		if c.Leaf.SerialNumber.Cmp(old.Leaf.SerialNumber) != 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c, _ := getCertificate(&tls.ClientHelloInfo{})
+	assert.NotEqual(t, old.Leaf.SerialNumber, c.Leaf.SerialNumber)
+
+	assert.Equal(t, -1, tc.index(keyFile, certFile))
+}
+
+func TestTLSCertificatesIsPairFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "air")
+	defer os.RemoveAll(dir)
+
+	os.Mkdir(filepath.Join(dir, "..2020_01_01"), 0755)
+	cf, kf := writeCertificate(filepath.Join(dir, "..2020_01_01"), "localhost")
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.Symlink("..2020_01_01", filepath.Join(dir, "..data")); err != nil {
+		t.Skip(err)
+	}
+	os.Symlink(filepath.Join("..data", filepath.Base(cf)), certFile)
+	os.Symlink(filepath.Join("..data", filepath.Base(kf)), keyFile)
+
+	tc := &tlsCertificates{
+		pairs: []TLSCertificateConfig{{CertFile: certFile, KeyFile: keyFile}},
+	}
+
+	assert.True(t, tc.isPairFile(certFile))
+	assert.True(t, tc.isPairFile(keyFile))
+	assert.True(t, tc.isPairFile(filepath.Join(dir, "..data")))
+	assert.False(t, tc.isPairFile(filepath.Join(dir, "..2020_01_01")))
+	assert.False(t, tc.isPairFile(filepath.Join(dir, "..data_tmp")))
+	assert.False(t, tc.isPairFile(filepath.Join(dir, "foobar.pem")))
+}
+
+func TestTLSVersion(t *testing.T) {
+	v, err := tlsVersion("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0), v)
+
+	v, err = tlsVersion("1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+
+	_, err = tlsVersion("1.4")
+	assert.Error(t, err)
+}
+
+func TestTLSClientAuth(t *testing.T) {
+	ca, err := tlsClientAuth("")
+	assert.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, ca)
+
+	ca, err = tlsClientAuth("require_and_verify")
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, ca)
+
+	_, err = tlsClientAuth("require")
+	assert.Error(t, err)
+}
+
+func TestTLSCipherSuites(t *testing.T) {
+	ids, err := tlsCipherSuites(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, ids)
+
+	ids, err = tlsCipherSuites([]string{
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}, ids)
+
+	_, err = tlsCipherSuites([]string{"TLS_FOOBAR"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+package air
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// upgradeListenFDsEnv is the environment variable that tells the new process started by the
+// `Air#Upgrade()` how many listeners it inherits.
+const upgradeListenFDsEnv = "AIR_UPGRADE_LISTEN_FDS"
+
+// Upgrade upgrades the binary without closing the listening sockets. It starts a new process of
+// the binary at the `os.Args[0]` with the same arguments, which inherits the listeners of the
+// HTTP server and serves on them instead of listening on the addresses. Once the new process is
+// ready, the HTTP server will be gracefully shut down by the `Air#Shutdown()` in the background
+// within the `Config#GracefulShutdownTimeout` if it is greater than zero.
+//
+// It returns an error and keeps the HTTP server serving if the new process exits before it is
+// ready, or if it is not ready within the `Config#UpgradeTimeout`, in which case it will be
+// killed. Only one upgrade can be in progress at a time, and the HTTP server cannot be upgraded
+// again once it has been upgraded. It's not supported on Windows.
+func (a *Air) Upgrade() error {
+	if !atomic.CompareAndSwapInt32(&a.upgrading, 0, 1) {
+		return errors.New("the HTTP server is being or has been upgraded")
+	}
+
+	if err := a.upgrade(); err != nil {
+		atomic.StoreInt32(&a.upgrading, 0)
+		return err
+	}
+
+	return nil
+}
+
+// upgrade implements the `Upgrade()`.
+func (a *Air) upgrade() error {
+	a.server.listenersMutex.Lock()
+	ls := append([]net.Listener(nil), a.server.listeners...)
+	a.server.listenersMutex.Unlock()
+
+	if len(ls) == 0 {
+		return errors.New("the HTTP server is not serving")
+	}
+
+	files := make([]*os.File, 0, len(ls)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, l := range ls {
+		f, err := listenerFile(l)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, f)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	files = append(files, w)
+
+	// The `os.Executable()` is not used since it may return the path of
+	// the old binary that has been replaced.
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(
+		upgradeEnviron(),
+		upgradeListenFDsEnv+"="+strconv.Itoa(len(ls)),
+	)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go cmd.Wait()
+
+	a.Logger.Infof("upgrading to the process %d", cmd.Process.Pid)
+
+	w.Close()
+	files = files[:len(ls)]
+
+	if a.Config.UpgradeTimeout > 0 {
+		r.SetReadDeadline(time.Now().Add(a.Config.UpgradeTimeout))
+	}
+
+	if _, err := r.Read(make([]byte, 1)); os.IsTimeout(err) {
+		cmd.Process.Kill()
+		return fmt.Errorf(
+			"the process %d is not ready within %v",
+			cmd.Process.Pid,
+			a.Config.UpgradeTimeout,
+		)
+	} else if err != nil {
+		return fmt.Errorf(
+			"the process %d exited before being ready",
+			cmd.Process.Pid,
+		)
+	}
+
+	// The Unix domain sockets are taken over by the new process, so
+	// they must not be removed when the HTTP server is shut down.
+	for _, l := range ls {
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	go func() {
+		ctx := context.Background()
+		if a.Config.GracefulShutdownTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(
+				ctx,
+				a.Config.GracefulShutdownTimeout,
+			)
+			defer cancel()
+		}
+
+		if err := a.Shutdown(ctx); err != nil {
+			a.Logger.Error(err)
+		}
+	}()
+
+	return nil
+}
+
+// upgradeOnSignal calls the `Air#Upgrade()` every time a signal is received from the sc until the
+// done is closed.
+func (a *Air) upgradeOnSignal(sc chan os.Signal, done chan struct{}) {
+	for {
+		select {
+		case s := <-sc:
+			a.Logger.Infof("upgrading on %v", s)
+			if err := a.Upgrade(); err != nil {
+				a.Logger.Error(err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// upgradeEnviron returns the environment of the current process without the environment variables
+// about the inherited listeners.
+func upgradeEnviron() []string {
+	env := os.Environ()
+	for i := 0; i < len(env); i++ {
+		if strings.HasPrefix(env[i], upgradeListenFDsEnv+"=") ||
+			strings.HasPrefix(env[i], "LISTEN_PID=") ||
+			strings.HasPrefix(env[i], "LISTEN_FDS=") ||
+			strings.HasPrefix(env[i], "LISTEN_FDNAMES=") {
+			env = append(env[:i], env[i+1:]...)
+			i--
+		}
+	}
+	return env
+}
+
+// upgradeListeners returns the listeners inherited from the parent process by the
+// `Air#Upgrade()` and the file used to notify the parent process that the current process is
+// ready. It returns nil if the "AIR_UPGRADE_LISTEN_FDS" is not set. The environment variable will
+// be unset once it is used.
+func upgradeListeners() ([]net.Listener, *os.File, error) {
+	n, err := strconv.Atoi(os.Getenv(upgradeListenFDsEnv))
+	if err != nil || n <= 0 {
+		return nil, nil, nil
+	}
+
+	os.Unsetenv(upgradeListenFDsEnv)
+
+	ready := os.NewFile(uintptr(listenFDsStart+n), "AIR_UPGRADE_READY")
+
+	ls, err := fileListeners(n, nil)
+	if err != nil {
+		ready.Close()
+		return nil, nil, err
+	}
+
+	return ls, ready, nil
+}
+
+// notifyUpgradeReady notifies the parent process through the ready that the current process is
+// ready to take over the inherited listeners, and closes the ready.
+func notifyUpgradeReady(ready *os.File) {
+	ready.Write([]byte{1})
+	ready.Close()
+}
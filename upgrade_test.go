@@ -0,0 +1,127 @@
+//go:build !windows
+// +build !windows
+
+package air
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirUpgrade(t *testing.T) {
+	if os.Getenv(upgradeListenFDsEnv) != "" { // In the new process
+		a := New()
+		if os.Getenv("AIR_UPGRADE_TEST_HANG") != "" {
+			a.OnStart(func() error {
+				time.Sleep(time.Hour)
+				return nil
+			})
+		}
+
+		a.GET("/", func(c *Context) error {
+			return c.String("new")
+		})
+		a.GET("/shutdown", func(c *Context) error {
+			go a.Shutdown(context.Background())
+			return c.NoContent()
+		})
+
+		assert.NoError(t, a.Serve())
+
+		return
+	}
+
+	defer func(args []string, stdout, stderr *os.File) {
+		os.Args = args
+		os.Stdout = stdout
+		os.Stderr = stderr
+	}(os.Args, os.Stdout, os.Stderr)
+
+	a := New()
+	a.Config.Address = freeAddress()
+	a.GET("/", func(c *Context) error {
+		return c.String("old")
+	})
+
+	assert.Error(t, a.Upgrade())
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	client := &http.Client{
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+
+	get := func(path string) string {
+		res, err := client.Get("http://" + a.Config.Address + path)
+		if err != nil {
+			return err.Error()
+		}
+		defer res.Body.Close()
+
+		b, _ := ioutil.ReadAll(res.Body)
+		return string(b)
+	}
+
+	assert.Equal(t, "old", get("/"))
+
+	// Keeps the outputs of the new processes out of the current one.
+	devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer devNull.Close()
+
+	os.Stdout, os.Stderr = devNull, devNull
+
+	os.Args = []string{os.Args[0], "-test.run=^$"}
+	assert.Error(t, a.Upgrade())
+	assert.Equal(t, "old", get("/"))
+
+	os.Args = []string{os.Args[0], "-test.run=^TestAirUpgrade$"}
+
+	os.Setenv("AIR_UPGRADE_TEST_HANG", "1")
+	a.Config.UpgradeTimeout = 500 * time.Millisecond
+
+	upgraded := make(chan error)
+	go func() {
+		upgraded <- a.Upgrade()
+	}()
+
+	for atomic.LoadInt32(&a.upgrading) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Error(t, a.Upgrade())
+	assert.Regexp(t, "is not ready within", <-upgraded)
+	assert.Equal(t, "old", get("/"))
+
+	os.Unsetenv("AIR_UPGRADE_TEST_HANG")
+
+	assert.NoError(t, a.Upgrade())
+	assert.Error(t, a.Upgrade())
+	assert.NoError(t, <-served)
+	assert.Equal(t, "new", get("/"))
+	assert.Equal(t, "", get("/shutdown"))
+}
+
+func TestUpgradeEnviron(t *testing.T) {
+	os.Setenv(upgradeListenFDsEnv, "1")
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	defer os.Unsetenv(upgradeListenFDsEnv)
+	defer os.Unsetenv("LISTEN_PID")
+
+	env := upgradeEnviron()
+	assert.Len(t, env, len(os.Environ())-2)
+	assert.NotContains(t, env, upgradeListenFDsEnv+"=1")
+	assert.NotContains(t, env, "LISTEN_PID="+strconv.Itoa(os.Getpid()))
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+// +build !windows
+
+package air
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// upgradeSignals is the signals that trigger the `Air#Upgrade()`.
+var upgradeSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
+
+// listenerFile returns a duplicate of the file of the l.
+//
+// The `File()` of the `net.TCPListener` and the `net.UnixListener` is not used since it puts the
+// l into the blocking mode, which prevents the l from being closed while it is accepting.
+func listenerFile(l net.Listener) (*os.File, error) {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("the listener of %s cannot be inherited", l.Addr())
+	}
+
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	fd := -1
+	if cerr := rc.Control(func(lfd uintptr) {
+		syscall.ForkLock.RLock()
+		defer syscall.ForkLock.RUnlock()
+
+		if fd, err = syscall.Dup(int(lfd)); err == nil {
+			syscall.CloseOnExec(fd)
+		}
+	}); cerr != nil {
+		return nil, cerr
+	} else if err != nil {
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), l.Addr().String()), nil
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package air
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// upgradeSignals is the signals that trigger the `Air#Upgrade()`. There are none on Windows.
+var upgradeSignals []os.Signal
+
+// listenerFile returns a duplicate of the file of the l. It's not supported on Windows.
+func listenerFile(l net.Listener) (*os.File, error) {
+	return nil, errors.New("the upgrade is not supported on windows")
+}
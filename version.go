@@ -0,0 +1,144 @@
+package air
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// version is an API version that has its own router.
+type version struct {
+	name   string
+	router *router
+}
+
+// Version returns a pointer of a `Group` that registers the routes of the API version v with the
+// optional group-level gases.
+//
+// The API version of the HTTP request is picked from the path prefix (e.g. "/v2/users"), the
+// header named by the `Config#VersionHeader`, or the "version" param of the media types in the
+// "Accept" header (e.g. "application/vnd.acme+json;version=2"), in that order. The path prefix
+// will be stripped before routing. The HTTP request will be routed by the newest API version that
+// is not newer than the requested one and has a route matched, or by the routes registered in the
+// a itself if there is not. The chosen API version is available through the `Context#Version()`.
+func (a *Air) Version(v string, gases ...Gas) *Group {
+	i := 0
+	for ; i < len(a.versions); i++ {
+		if c := compareVersions(a.versions[i].name, v); c == 0 {
+			return newGroup(a, a.versions[i].router, "", gases)
+		} else if c > 0 {
+			break
+		}
+	}
+
+	ver := &version{
+		name:   v,
+		router: newRouter(a),
+	}
+
+	ver.router.version = v
+
+	a.versions = append(a.versions, nil)
+	copy(a.versions[i+1:], a.versions[i:])
+	a.versions[i] = ver
+
+	return newGroup(a, ver.router, "", gases)
+}
+
+// routeVersion routes the HTTP request of the c by the API versions. It reports whether there is a
+// route matched.
+func (a *Air) routeVersion(c *Context) bool {
+	if len(a.versions) == 0 {
+		return false
+	}
+
+	rv, path := a.requestedVersion(c)
+	if rv == "" {
+		return false
+	}
+
+	for i := len(a.versions) - 1; i >= 0; i-- {
+		v := a.versions[i]
+		if compareVersions(v.name, rv) > 0 {
+			continue
+		}
+
+		c.ParamValues = c.ParamValues[:0]
+		if v.router.route(c.Request.Method, path, c); c.allowedMethods != nil {
+			c.version = v.name
+			return true
+		}
+	}
+
+	c.ParamValues = c.ParamValues[:0]
+
+	return false
+}
+
+// requestedVersion returns the API version requested by the c and the path of the c without the
+// version prefix.
+func (a *Air) requestedVersion(c *Context) (string, string) {
+	path := c.Request.URL.EscapedPath()
+
+	i := 1
+	for ; i < len(path) && path[i] != '/'; i++ {
+	}
+
+	if i > 2 && path[1] == 'v' && isVersion(path[2:i]) {
+		if i == len(path) {
+			return path[2:i], "/"
+		}
+		return path[2:i], path[i:]
+	}
+
+	if a.Config.VersionHeader != "" {
+		if v := c.Request.Header.Get(a.Config.VersionHeader); v != "" {
+			return v, path
+		}
+	}
+
+	for _, mt := range strings.Split(c.Request.Header.Get(HeaderAccept), ",") {
+		if _, params, err := mime.ParseMediaType(mt); err == nil && params["version"] != "" {
+			return params["version"], path
+		}
+	}
+
+	return "", path
+}
+
+// isVersion reports whether the s is a version consisting of the dot-separated numbers.
+func isVersion(s string) bool {
+	for _, p := range strings.Split(s, ".") {
+		if !isUint(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareVersions compares the versions v1 and v2 part by part. The numeric parts are compared
+// numerically. It returns -1 if the v1 is older, 1 if the v1 is newer, otherwise 0.
+func compareVersions(v1, v2 string) int {
+	ps1, ps2 := strings.Split(v1, "."), strings.Split(v2, ".")
+	for len(ps1) < len(ps2) {
+		ps1 = append(ps1, "0")
+	}
+	for len(ps2) < len(ps1) {
+		ps2 = append(ps2, "0")
+	}
+
+	for i := range ps1 {
+		n1, err1 := strconv.Atoi(ps1[i])
+		n2, err2 := strconv.Atoi(ps2[i])
+		switch {
+		case err1 == nil && err2 == nil && n1 < n2,
+			(err1 != nil || err2 != nil) && ps1[i] < ps2[i]:
+			return -1
+		case err1 == nil && err2 == nil && n1 > n2,
+			(err1 != nil || err2 != nil) && ps1[i] > ps2[i]:
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,79 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirVersion(t *testing.T) {
+	a := New()
+
+	h := func(c *Context) error {
+		return c.String(c.Version() + " " + c.PristinePath + " " + c.Param("id"))
+	}
+
+	a.GET("/status", h)
+	a.Version("1").GET("/users/:id", h)
+	a.Version("1").GET("/posts", h)
+	a.Version("2").GET("/users/:id", h)
+	a.Version("1.5").GET("/posts", h)
+
+	assert.Len(t, a.versions, 3)
+	assert.Equal(t, "1", a.versions[0].name)
+	assert.Equal(t, "1.5", a.versions[1].name)
+	assert.Equal(t, "2", a.versions[2].name)
+
+	cases := []struct {
+		target string
+		header string
+		accept string
+		code   int
+		body   string
+	}{
+		{"/v2/users/1", "", "", http.StatusOK, "2 /users/:id 1"},
+		{"/v1/users/1", "", "", http.StatusOK, "1 /users/:id 1"},
+		{"/v3/users/1", "", "", http.StatusOK, "2 /users/:id 1"},
+		{"/v2/posts", "", "", http.StatusOK, "1.5 /posts "},
+		{"/users/1", "2", "", http.StatusOK, "2 /users/:id 1"},
+		{"/users/1", "", "application/vnd.acme+json; version=1", http.StatusOK,
+			"1 /users/:id 1"},
+		{"/status", "2", "", http.StatusOK, " /status "},
+		{"/users/1", "", "", http.StatusNotFound, ""},
+		{"/v0/users/1", "", "", http.StatusNotFound, ""},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(GET, c.target, nil)
+		if c.header != "" {
+			req.Header.Set("X-API-Version", c.header)
+		}
+		if c.accept != "" {
+			req.Header.Set(HeaderAccept, c.accept)
+		}
+		rec := httptest.NewRecorder()
+		a.ServeHTTP(rec, req)
+		assert.Equal(t, c.code, rec.Code, c.target)
+		if c.body != "" {
+			assert.Equal(t, c.body, rec.Body.String(), c.target)
+		}
+	}
+
+	ris := a.Routes()
+	assert.Len(t, ris, 5)
+	assert.Equal(t, "", ris[0].Version)
+	assert.Equal(t, "1", ris[1].Version)
+	assert.Equal(t, "2", ris[4].Version)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("1", "1.0"))
+	assert.Equal(t, -1, compareVersions("1.5", "1.10"))
+	assert.Equal(t, 1, compareVersions("2", "1.10"))
+	assert.Equal(t, -1, compareVersions("1.beta", "1.rc"))
+	assert.True(t, isVersion("2.0.1"))
+	assert.False(t, isVersion("2.x"))
+	assert.False(t, isVersion(""))
+}
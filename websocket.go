@@ -0,0 +1,778 @@
+package air
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocketHandler defines a function to serve the WebSocket connections.
+type WebSocketHandler func(*WebSocketConn) error
+
+// WebSocketMessageType is the type of the WebSocket messages.
+type WebSocketMessageType uint8
+
+// WebSocket message types
+const (
+	WebSocketMessageText   WebSocketMessageType = 0x1
+	WebSocketMessageBinary WebSocketMessageType = 0x2
+)
+
+// WebSocket close codes
+const (
+	WebSocketCloseNormalClosure           = 1000
+	WebSocketCloseGoingAway               = 1001
+	WebSocketCloseProtocolError           = 1002
+	WebSocketCloseUnsupportedData         = 1003
+	WebSocketCloseNoStatusReceived        = 1005
+	WebSocketCloseAbnormalClosure         = 1006
+	WebSocketCloseInvalidFramePayloadData = 1007
+	WebSocketClosePolicyViolation         = 1008
+	WebSocketCloseMessageTooBig           = 1009
+	WebSocketCloseMandatoryExtension      = 1010
+	WebSocketCloseInternalServerError     = 1011
+)
+
+// WebSocket opcodes
+const (
+	webSocketOpContinuation = 0x0
+	webSocketOpText         = 0x1
+	webSocketOpBinary       = 0x2
+	webSocketOpClose        = 0x8
+	webSocketOpPing         = 0x9
+	webSocketOpPong         = 0xa
+)
+
+// webSocketGUID is the GUID used to compute the "Sec-WebSocket-Accept" header.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// webSocketDeflateTail is the tail that the permessage-deflate removes from the end of every
+// compressed message, followed by an empty final block to terminate the DEFLATE stream.
+const webSocketDeflateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+// errWebSocketClosed is the error of writing to the closed WebSocket connections.
+var errWebSocketClosed = errors.New("the WebSocket connection is closed")
+
+// WebSocketCloseError is the error returned by the `WebSocketConn#ReadMessage()` once the
+// WebSocket connection is closed by a close frame, either the one received from the client or the
+// one sent because the client violated the protocol.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error implements the `error#Error()`.
+func (wsce *WebSocketCloseError) Error() string {
+	return fmt.Sprintf(
+		"the WebSocket connection is closed with %d %s",
+		wsce.Code,
+		wsce.Reason,
+	)
+}
+
+// WebSocketConn is a WebSocket connection upgraded from an HTTP connection.
+//
+// The `ReadMessage()` must not be called concurrently. The write methods are safe for concurrent
+// use.
+type WebSocketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	server *server
+
+	compressed      bool
+	maxMessageBytes int
+	readErr         error
+	writeMutex      sync.Mutex
+	closeSent       bool
+	closeOnce       sync.Once
+	closeErr        error
+
+	// Context is the `Context` of the HTTP request that upgraded the `WebSocketConn`. It is
+	// only valid until the `Handler` that upgraded the `WebSocketConn` returns.
+	Context *Context
+
+	// Subprotocol is the subprotocol chosen from the `Config#WebSocketSubprotocols`. It's
+	// empty if none of them is requested by the client.
+	Subprotocol string
+
+	// PingHandler handles the application data of the ping frames received by the
+	// `ReadMessage()`. The default one replies a pong frame with the same application data.
+	PingHandler func(appData string) error
+
+	// PongHandler handles the application data of the pong frames received by the
+	// `ReadMessage()`. The pong frames will be ignored if it is nil.
+	PongHandler func(appData string) error
+}
+
+// WebSocket registers a new GET route for the path to serve the WebSocket connections with the h
+// in the router with the optional route-level gases. The HTTP requests of the route will be
+// upgraded by the `Context#UpgradeWebSocket()` before the h is called, and the `WebSocketConn`
+// will be closed after the h returns. The `WebSocketConn` will be closed with the
+// `WebSocketCloseInternalServerError` if the h returns an error except the
+// `WebSocketCloseError`.
+func (a *Air) WebSocket(path string, h WebSocketHandler, gases ...Gas) *Route {
+	return a.GET(path, webSocketHandler(h), gases...)
+}
+
+// webSocketHandler returns a `Handler` that upgrades the HTTP requests to the WebSocket
+// connections and serves them with the h.
+func webSocketHandler(h WebSocketHandler) Handler {
+	return func(c *Context) error {
+		wsc, err := c.UpgradeWebSocket()
+		if err != nil {
+			return err
+		}
+		defer wsc.Close()
+
+		err = h(wsc)
+		if _, ok := err.(*WebSocketCloseError); ok {
+			return nil
+		} else if err != nil {
+			wsc.WriteClose(WebSocketCloseInternalServerError, "")
+		}
+
+		return err
+	}
+}
+
+// upgradeWebSocket upgrades the HTTP connection of the c to a WebSocket connection. It implements
+// the `Context#UpgradeWebSocket()`.
+func upgradeWebSocket(c *Context) (*WebSocketConn, error) {
+	req := c.Request
+	if req.Method != GET ||
+		!headerHasToken(req.Header, HeaderConnection, "upgrade") ||
+		!headerHasToken(req.Header, HeaderUpgrade, "websocket") {
+		return nil, ErrBadRequest
+	}
+
+	if req.Header.Get(HeaderSecWebSocketVersion) != "13" {
+		c.Response.Header().Set(HeaderSecWebSocketVersion, "13")
+		return nil, ErrUpgradeRequired
+	}
+
+	key := req.Header.Get(HeaderSecWebSocketKey)
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, ErrBadRequest
+	}
+
+	if !webSocketOriginAllowed(c) {
+		return nil, ErrForbidden
+	}
+
+	if c.Response.Hijacker == nil {
+		return nil, errors.New("the HTTP connection cannot be upgraded to a WebSocket " +
+			"connection")
+	} else if c.Response.Written {
+		return nil, errors.New("response already written")
+	}
+
+	wsc := &WebSocketConn{
+		server:          c.Air.server,
+		maxMessageBytes: c.Air.Config.WebSocketMaxMessageBytes,
+		Context:         c,
+		Subprotocol:     webSocketSubprotocol(c),
+	}
+
+	wsc.compressed = c.Air.Config.WebSocketCompressionEnabled &&
+		webSocketDeflateRequested(req.Header)
+
+	if !wsc.server.trackWebSocket(wsc) {
+		return nil, ErrServiceUnavailable
+	}
+
+	conn, brw, err := c.Response.Hijack()
+	if err != nil {
+		wsc.server.untrackWebSocket(wsc)
+		return nil, err
+	}
+
+	// The deadlines set by the HTTP server must not affect the
+	// WebSocket connection.
+	conn.SetDeadline(time.Time{})
+
+	wsc.conn = conn
+	wsc.reader = brw.Reader
+
+	h := sha1.Sum([]byte(key + webSocketGUID))
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	buf.WriteString("Upgrade: websocket\r\n")
+	buf.WriteString("Connection: Upgrade\r\n")
+	buf.WriteString(HeaderSecWebSocketAccept + ": ")
+	buf.WriteString(base64.StdEncoding.EncodeToString(h[:]))
+	buf.WriteString("\r\n")
+	if wsc.Subprotocol != "" {
+		buf.WriteString(HeaderSecWebSocketProtocol + ": " + wsc.Subprotocol + "\r\n")
+	}
+
+	if wsc.compressed {
+		buf.WriteString(HeaderSecWebSocketExtensions + ": permessage-deflate; " +
+			"server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+
+	c.Response.Header().Write(&buf)
+	buf.WriteString("\r\n")
+
+	c.Response.StatusCode = http.StatusSwitchingProtocols
+	c.Response.Written = true
+
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		conn.Close()
+		wsc.server.untrackWebSocket(wsc)
+		return nil, err
+	}
+
+	return wsc, nil
+}
+
+// ReadMessage reads the next text or binary message from the wsc. The control frames received
+// before the message are handled by the `PingHandler` and the `PongHandler`, or used to close
+// the wsc. It returns a `WebSocketCloseError` once the wsc is closed by a close frame.
+func (wsc *WebSocketConn) ReadMessage() (WebSocketMessageType, []byte, error) {
+	if wsc.readErr != nil {
+		return 0, nil, wsc.readErr
+	}
+
+	var (
+		started    bool
+		mt         WebSocketMessageType
+		compressed bool
+		message    []byte
+	)
+
+	for {
+		fin, rsv1, opcode, payload, err := wsc.readFrame(len(message))
+		if err != nil {
+			return 0, nil, wsc.failRead(err)
+		}
+
+		switch opcode {
+		case webSocketOpText, webSocketOpBinary:
+			if started {
+				return 0, nil, wsc.failRead(&WebSocketCloseError{
+					Code:   WebSocketCloseProtocolError,
+					Reason: "unexpected data frame",
+				})
+			}
+
+			started = true
+			mt = WebSocketMessageType(opcode)
+			compressed = rsv1
+		case webSocketOpContinuation:
+			if !started || rsv1 {
+				return 0, nil, wsc.failRead(&WebSocketCloseError{
+					Code:   WebSocketCloseProtocolError,
+					Reason: "unexpected continuation frame",
+				})
+			}
+		case webSocketOpClose:
+			return 0, nil, wsc.handleClose(payload)
+		case webSocketOpPing:
+			h := wsc.PingHandler
+			if h == nil {
+				h = func(appData string) error {
+					err := wsc.WritePong([]byte(appData))
+					if err == errWebSocketClosed {
+						return nil
+					}
+					return err
+				}
+			}
+
+			if err := h(string(payload)); err != nil {
+				return 0, nil, err
+			}
+
+			continue
+		case webSocketOpPong:
+			if wsc.PongHandler != nil {
+				if err := wsc.PongHandler(string(payload)); err != nil {
+					return 0, nil, err
+				}
+			}
+
+			continue
+		}
+
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+
+		if compressed {
+			if message, err = wsc.decompress(message); err != nil {
+				return 0, nil, wsc.failRead(err)
+			}
+		}
+
+		if mt == WebSocketMessageText && !utf8.Valid(message) {
+			return 0, nil, wsc.failRead(&WebSocketCloseError{
+				Code:   WebSocketCloseInvalidFramePayloadData,
+				Reason: "invalid UTF-8 text",
+			})
+		}
+
+		return mt, message, nil
+	}
+}
+
+// readFrame reads the next frame from the wsc. The read is the number of bytes of the message
+// that have been read before the frame.
+func (wsc *WebSocketConn) readFrame(read int) (bool, bool, byte, []byte, error) {
+	header := make([]byte, 2, 14)
+	if _, err := io.ReadFull(wsc.reader, header); err != nil {
+		return false, false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	rsv1 := header[0]&0x40 != 0
+	opcode := header[0] & 0x0f
+	control := opcode&0x08 != 0
+	masked := header[1]&0x80 != 0
+
+	protocolError := func(reason string) (bool, bool, byte, []byte, error) {
+		return false, false, 0, nil, &WebSocketCloseError{
+			Code:   WebSocketCloseProtocolError,
+			Reason: reason,
+		}
+	}
+
+	switch {
+	case header[0]&0x30 != 0, rsv1 && (!wsc.compressed || control):
+		return protocolError("unexpected reserved bits")
+	case opcode > webSocketOpBinary && !control, opcode > webSocketOpPong:
+		return protocolError("unknown opcode")
+	case !masked:
+		return protocolError("unmasked frame")
+	case control && (!fin || header[1]&0x7f > 125):
+		return protocolError("invalid control frame")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		b := header[2:4]
+		if _, err := io.ReadFull(wsc.reader, b); err != nil {
+			return false, false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(b))
+	case 127:
+		b := header[2:10]
+		if _, err := io.ReadFull(wsc.reader, b); err != nil {
+			return false, false, 0, nil, err
+		}
+
+		length = binary.BigEndian.Uint64(b)
+		if length>>63 != 0 {
+			return protocolError("invalid payload length")
+		}
+	}
+
+	if !control && wsc.maxMessageBytes > 0 &&
+		uint64(read)+length > uint64(wsc.maxMessageBytes) {
+		return false, false, 0, nil, &WebSocketCloseError{
+			Code:   WebSocketCloseMessageTooBig,
+			Reason: "message too big",
+		}
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(wsc.reader, mask); err != nil {
+		return false, false, 0, nil, err
+	}
+
+	// The payload is read in chunks instead of being allocated by the
+	// length declared by the client, which is not limited if the maximum
+	// number of bytes of the message is not greater than zero.
+	buf := bytes.Buffer{}
+	if _, err := io.CopyN(&buf, wsc.reader, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return false, false, 0, nil, err
+	}
+
+	payload := buf.Bytes()
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, rsv1, opcode, payload, nil
+}
+
+// handleClose handles the payload of the close frame received from the client. It replies a close
+// frame with the same status code and closes the wsc.
+func (wsc *WebSocketConn) handleClose(payload []byte) error {
+	wsce := &WebSocketCloseError{Code: WebSocketCloseNoStatusReceived}
+	if len(payload) >= 2 {
+		wsce.Code = int(binary.BigEndian.Uint16(payload))
+		wsce.Reason = string(payload[2:])
+		if !webSocketCloseCodeValid(wsce.Code) || !utf8.ValidString(wsce.Reason) {
+			return wsc.failRead(&WebSocketCloseError{
+				Code:   WebSocketCloseProtocolError,
+				Reason: "invalid close frame",
+			})
+		}
+
+		wsc.writeClose(wsce.Code, "")
+	} else if len(payload) == 1 {
+		return wsc.failRead(&WebSocketCloseError{
+			Code:   WebSocketCloseProtocolError,
+			Reason: "invalid close frame",
+		})
+	} else {
+		wsc.writeFrame(webSocketOpClose, false, nil)
+	}
+
+	wsc.readErr = wsce
+	wsc.Close()
+
+	return wsce
+}
+
+// failRead fails the reading of the wsc with the err. The wsc will be closed with the close frame
+// of the err if it is a `WebSocketCloseError`.
+func (wsc *WebSocketConn) failRead(err error) error {
+	if wsce, ok := err.(*WebSocketCloseError); ok {
+		wsc.writeClose(wsce.Code, wsce.Reason)
+	}
+
+	wsc.readErr = err
+	wsc.Close()
+
+	return err
+}
+
+// decompress decompresses the message compressed by the permessage-deflate. The decompressed
+// message must not exceed the maximum number of bytes of the wsc.
+func (wsc *WebSocketConn) decompress(message []byte) ([]byte, error) {
+	var r io.Reader = flate.NewReader(io.MultiReader(
+		bytes.NewReader(message),
+		strings.NewReader(webSocketDeflateTail),
+	))
+	if wsc.maxMessageBytes > 0 {
+		r = io.LimitReader(r, int64(wsc.maxMessageBytes)+1)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, &WebSocketCloseError{
+			Code:   WebSocketCloseInvalidFramePayloadData,
+			Reason: "invalid compressed message",
+		}
+	} else if wsc.maxMessageBytes > 0 && len(b) > wsc.maxMessageBytes {
+		return nil, &WebSocketCloseError{
+			Code:   WebSocketCloseMessageTooBig,
+			Reason: "message too big",
+		}
+	}
+
+	return b, nil
+}
+
+// WriteMessage writes the data as a message of the mt to the wsc. The data will be compressed if
+// the per-message compression is enabled.
+func (wsc *WebSocketConn) WriteMessage(mt WebSocketMessageType, data []byte) error {
+	if mt != WebSocketMessageText && mt != WebSocketMessageBinary {
+		return fmt.Errorf("the WebSocket message type %d is invalid", mt)
+	} else if !wsc.compressed {
+		return wsc.writeFrame(byte(mt), false, data)
+	}
+
+	var buf bytes.Buffer
+
+	fw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	fw.Write(data)
+	fw.Flush()
+
+	return wsc.writeFrame(byte(mt), true, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}))
+}
+
+// WriteText writes the text as a text message to the wsc.
+func (wsc *WebSocketConn) WriteText(text string) error {
+	return wsc.WriteMessage(WebSocketMessageText, []byte(text))
+}
+
+// WriteBinary writes the b as a binary message to the wsc.
+func (wsc *WebSocketConn) WriteBinary(b []byte) error {
+	return wsc.WriteMessage(WebSocketMessageBinary, b)
+}
+
+// WritePing writes a ping frame with the appData to the wsc. The appData must not exceed 125
+// bytes.
+func (wsc *WebSocketConn) WritePing(appData []byte) error {
+	if len(appData) > 125 {
+		return errors.New("the WebSocket control frame payload exceeds 125 bytes")
+	}
+	return wsc.writeFrame(webSocketOpPing, false, appData)
+}
+
+// WritePong writes a pong frame with the appData to the wsc. The appData must not exceed 125
+// bytes.
+func (wsc *WebSocketConn) WritePong(appData []byte) error {
+	if len(appData) > 125 {
+		return errors.New("the WebSocket control frame payload exceeds 125 bytes")
+	}
+	return wsc.writeFrame(webSocketOpPong, false, appData)
+}
+
+// WriteClose writes a close frame with the code and the reason to the wsc to start the closing
+// handshake. The wsc will be closed once the client replies a close frame. Nothing can be written
+// to the wsc after it.
+func (wsc *WebSocketConn) WriteClose(code int, reason string) error {
+	if !webSocketCloseCodeValid(code) {
+		return fmt.Errorf("the WebSocket close code %d is invalid", code)
+	}
+	return wsc.writeClose(code, reason)
+}
+
+// writeClose writes a close frame with the code and the reason to the wsc. The reason will be
+// truncated to fit in the close frame.
+func (wsc *WebSocketConn) writeClose(code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+
+	return wsc.writeFrame(webSocketOpClose, false, append(payload, reason...))
+}
+
+// writeFrame writes a final frame of the opcode with the payload to the wsc. The rsv1 indicates
+// whether the payload is compressed.
+func (wsc *WebSocketConn) writeFrame(opcode byte, rsv1 bool, payload []byte) error {
+	wsc.writeMutex.Lock()
+	defer wsc.writeMutex.Unlock()
+
+	if wsc.closeSent {
+		return errWebSocketClosed
+	} else if opcode == webSocketOpClose {
+		wsc.closeSent = true
+	}
+
+	frame := make([]byte, 2, 10+len(payload))
+	frame[0] = 0x80 | opcode
+	if rsv1 {
+		frame[0] |= 0x40
+	}
+
+	switch l := len(payload); {
+	case l <= 125:
+		frame[1] = byte(l)
+	case l <= 0xffff:
+		frame[1] = 126
+		frame = append(frame, byte(l>>8), byte(l))
+	default:
+		frame[1] = 127
+		frame = frame[:10]
+		binary.BigEndian.PutUint64(frame[2:], uint64(l))
+	}
+
+	_, err := wsc.conn.Write(append(frame, payload...))
+
+	return err
+}
+
+// SetReadDeadline sets the deadline of the future and the pending reads of the wsc. A zero value
+// for the t means the reads will not time out. The wsc cannot be read anymore once the deadline
+// is exceeded.
+func (wsc *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return wsc.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of the future and the pending writes of the wsc. A zero value
+// for the t means the writes will not time out.
+func (wsc *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return wsc.conn.SetWriteDeadline(t)
+}
+
+// Close closes the wsc. A close frame with the `WebSocketCloseNormalClosure` will be written to
+// the wsc first if no close frame has been written to it.
+func (wsc *WebSocketConn) Close() error {
+	wsc.closeOnce.Do(func() {
+		wsc.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		wsc.writeClose(WebSocketCloseNormalClosure, "")
+
+		wsc.closeErr = wsc.conn.Close()
+		wsc.server.untrackWebSocket(wsc)
+	})
+	return wsc.closeErr
+}
+
+// trackWebSocket adds the wsc into the WebSocket connections of the s. It returns false if the s
+// is shutting down.
+func (s *server) trackWebSocket(wsc *WebSocketConn) bool {
+	s.webSocketsMutex.Lock()
+	defer s.webSocketsMutex.Unlock()
+
+	if s.webSocketsClosed {
+		return false
+	} else if s.webSockets == nil {
+		s.webSockets = map[*WebSocketConn]struct{}{}
+	}
+
+	s.webSockets[wsc] = struct{}{}
+
+	return true
+}
+
+// untrackWebSocket removes the wsc from the WebSocket connections of the s.
+func (s *server) untrackWebSocket(wsc *WebSocketConn) {
+	s.webSocketsMutex.Lock()
+	delete(s.webSockets, wsc)
+	s.webSocketsMutex.Unlock()
+}
+
+// shutdownWebSockets gracefully closes the WebSocket connections of the s. It writes a close frame
+// with the `WebSocketCloseGoingAway` to all of them, and waits for them to be closed until the ctx
+// is done. The remaining ones will be closed immediately after that.
+func (s *server) shutdownWebSockets(ctx context.Context) error {
+	s.webSocketsMutex.Lock()
+	s.webSocketsClosed = true
+	for wsc := range s.webSockets {
+		go wsc.writeClose(WebSocketCloseGoingAway, "server shutting down")
+	}
+	s.webSocketsMutex.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.webSocketsMutex.Lock()
+		n := len(s.webSockets)
+		s.webSocketsMutex.Unlock()
+
+		if n == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			s.closeWebSockets()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeWebSockets closes the WebSocket connections of the s immediately.
+func (s *server) closeWebSockets() {
+	s.webSocketsMutex.Lock()
+	defer s.webSocketsMutex.Unlock()
+
+	s.webSocketsClosed = true
+	for wsc := range s.webSockets {
+		wsc.conn.Close()
+	}
+}
+
+// headerHasToken reports whether the comma-separated values of the header of the key in the h
+// contain the token case-insensitively.
+func headerHasToken(h http.Header, key, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(key)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketOriginAllowed reports whether the WebSocket handshake of the c is allowed by the
+// `Config#WebSocketOrigins`.
+func webSocketOriginAllowed(c *Context) bool {
+	origin := c.Request.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+
+	origins := c.Air.Config.WebSocketOrigins
+	if len(origins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, c.Request.Host)
+	}
+
+	for _, o := range origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// webSocketSubprotocol returns the first subprotocol requested by the WebSocket handshake of the c
+// that is in the `Config#WebSocketSubprotocols`.
+func webSocketSubprotocol(c *Context) string {
+	h := c.Request.Header
+	for _, v := range h[http.CanonicalHeaderKey(HeaderSecWebSocketProtocol)] {
+		for _, p := range strings.Split(v, ",") {
+			p = strings.TrimSpace(p)
+			for _, sp := range c.Air.Config.WebSocketSubprotocols {
+				if p == sp {
+					return p
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// webSocketDeflateRequested reports whether the permessage-deflate is requested in the h with the
+// parameters that can be accepted. The LZ77 sliding window of the server cannot be smaller than
+// 32,768 bytes.
+func webSocketDeflateRequested(h http.Header) bool {
+	for _, v := range h[http.CanonicalHeaderKey(HeaderSecWebSocketExtensions)] {
+		for _, ext := range strings.Split(v, ",") {
+			params := strings.Split(ext, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+
+			accepted := true
+			for _, p := range params[1:] {
+				p = strings.TrimSpace(p)
+				if strings.HasPrefix(p, "server_max_window_bits") &&
+					strings.Trim(strings.TrimPrefix(
+						strings.TrimPrefix(p, "server_max_window_bits"),
+						"=",
+					), `"`) != "15" {
+					accepted = false
+				}
+			}
+
+			if accepted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketCloseCodeValid reports whether the code can be sent in a close frame.
+func webSocketCloseCodeValid(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,520 @@
+package air
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirWebSocket(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.WebSocketSubprotocols = []string{"chat", "echo"}
+
+	pongs := make(chan string, 1)
+	a.WebSocket("/ws/:name", func(wsc *WebSocketConn) error {
+		wsc.PongHandler = func(appData string) error {
+			pongs <- appData
+			return nil
+		}
+
+		if err := wsc.WriteText("hello " + wsc.Context.Param("name")); err != nil {
+			return err
+		}
+
+		for {
+			mt, b, err := wsc.ReadMessage()
+			if err != nil {
+				return err
+			}
+
+			if err := wsc.WriteMessage(mt, b); err != nil {
+				return err
+			}
+		}
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	conn, r, res, err := dialWebSocket(a.Config.Address, "/ws/air", http.Header{
+		HeaderSecWebSocketProtocol: []string{"foo, echo", "chat"},
+	})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	assert.Equal(
+		t,
+		"s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+		res.Header.Get(HeaderSecWebSocketAccept),
+	)
+	assert.Equal(t, "echo", res.Header.Get(HeaderSecWebSocketProtocol))
+	assert.Empty(t, res.Header.Get(HeaderSecWebSocketExtensions))
+
+	b0, payload, _ := readWebSocketFrame(r)
+	assert.Equal(t, byte(0x81), b0)
+	assert.Equal(t, "hello air", string(payload))
+
+	writeWebSocketFrame(conn, 0x81, []byte("foo"))
+	b0, payload, _ = readWebSocketFrame(r)
+	assert.Equal(t, byte(0x81), b0)
+	assert.Equal(t, "foo", string(payload))
+
+	writeWebSocketFrame(conn, 0x02, []byte{1, 2})
+	writeWebSocketFrame(conn, 0x89, []byte("ping"))
+	writeWebSocketFrame(conn, 0x80, []byte{3})
+
+	b0, payload, _ = readWebSocketFrame(r)
+	assert.Equal(t, byte(0x8a), b0)
+	assert.Equal(t, "ping", string(payload))
+
+	b0, payload, _ = readWebSocketFrame(r)
+	assert.Equal(t, byte(0x82), b0)
+	assert.Equal(t, []byte{1, 2, 3}, payload)
+
+	writeWebSocketFrame(conn, 0x8a, []byte("pong"))
+	assert.Equal(t, "pong", <-pongs)
+
+	writeWebSocketFrame(conn, 0x88, []byte{0x03, 0xe8, 'b', 'y', 'e'})
+	b0, payload, _ = readWebSocketFrame(r)
+	assert.Equal(t, byte(0x88), b0)
+	assert.Equal(t, []byte{0x03, 0xe8}, payload)
+
+	_, _, err = readWebSocketFrame(r)
+	assert.Equal(t, io.EOF, err)
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+}
+
+func TestAirWebSocketHandshake(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.WebSocket("/", func(wsc *WebSocketConn) error {
+		return nil
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	res, err := http.Get("http://" + a.Config.Address)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	res.Body.Close()
+
+	for origin, code := range map[string]int{
+		"":                                 http.StatusSwitchingProtocols,
+		"http://" + a.Config.Address:       http.StatusSwitchingProtocols,
+		"https://example.com":              http.StatusForbidden,
+		"http://" + a.Config.Address + "x": http.StatusForbidden,
+	} {
+		conn, _, res, err := dialWebSocket(a.Config.Address, "/", http.Header{
+			HeaderOrigin: []string{origin},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, code, res.StatusCode, origin)
+		conn.Close()
+	}
+
+	a.Config.WebSocketOrigins = []string{"https://example.com"}
+
+	conn, _, res, err := dialWebSocket(a.Config.Address, "/", http.Header{
+		HeaderOrigin: []string{"https://EXAMPLE.com"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	conn.Close()
+
+	conn, _, res, err = dialWebSocket(a.Config.Address, "/", http.Header{
+		HeaderSecWebSocketVersion: []string{"8"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUpgradeRequired, res.StatusCode)
+	assert.Equal(t, "13", res.Header.Get(HeaderSecWebSocketVersion))
+	conn.Close()
+
+	conn, _, res, err = dialWebSocket(a.Config.Address, "/", http.Header{
+		HeaderSecWebSocketKey: []string{"foobar"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	conn.Close()
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+}
+
+func TestAirWebSocketCompression(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.WebSocketCompressionEnabled = true
+	a.WebSocket("/", func(wsc *WebSocketConn) error {
+		_, b, err := wsc.ReadMessage()
+		if err != nil {
+			return err
+		}
+		return wsc.WriteText(strings.ToUpper(string(b)))
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	conn, r, res, err := dialWebSocket(a.Config.Address, "/", http.Header{
+		HeaderSecWebSocketExtensions: []string{
+			"permessage-deflate; server_max_window_bits=10",
+			"permessage-deflate; client_max_window_bits",
+		},
+	})
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(
+		t,
+		"permessage-deflate; server_no_context_takeover; client_no_context_takeover",
+		res.Header.Get(HeaderSecWebSocketExtensions),
+	)
+
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	fw.Write([]byte(strings.Repeat("air", 100)))
+	fw.Flush()
+	writeWebSocketFrame(conn, 0xc1, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}))
+
+	b0, payload, _ := readWebSocketFrame(r)
+	assert.Equal(t, byte(0xc1), b0)
+
+	b, _ := ioutil.ReadAll(flate.NewReader(io.MultiReader(
+		bytes.NewReader(payload),
+		strings.NewReader(webSocketDeflateTail),
+	)))
+	assert.Equal(t, strings.Repeat("AIR", 100), string(b))
+
+	b0, payload, _ = readWebSocketFrame(r)
+	assert.Equal(t, byte(0x88), b0)
+	assert.Equal(t, []byte{0x03, 0xe8}, payload)
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+}
+
+func TestAirWebSocketProtocolErrors(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.WebSocketMaxMessageBytes = 8
+
+	errs := make(chan error, 1)
+	a.WebSocket("/", func(wsc *WebSocketConn) error {
+		_, _, err := wsc.ReadMessage()
+		errs <- err
+		return err
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	for _, c := range []struct {
+		b0      byte
+		payload []byte
+		masked  bool
+		code    int
+	}{
+		{0x81, []byte("foo"), false, WebSocketCloseProtocolError},
+		{0xc1, []byte("foo"), true, WebSocketCloseProtocolError},
+		{0x83, []byte("foo"), true, WebSocketCloseProtocolError},
+		{0x09, []byte("foo"), true, WebSocketCloseProtocolError},
+		{0x80, []byte("foo"), true, WebSocketCloseProtocolError},
+		{0x81, []byte{0xff}, true, WebSocketCloseInvalidFramePayloadData},
+		{0x82, make([]byte, 9), true, WebSocketCloseMessageTooBig},
+		{0x88, []byte{0x03, 0xed}, true, WebSocketCloseProtocolError},
+	} {
+		conn, r, _, err := dialWebSocket(a.Config.Address, "/", nil)
+		assert.NoError(t, err)
+
+		if c.masked {
+			writeWebSocketFrame(conn, c.b0, c.payload)
+		} else {
+			conn.Write(append([]byte{c.b0, byte(len(c.payload))}, c.payload...))
+		}
+
+		b0, payload, _ := readWebSocketFrame(r)
+		assert.Equal(t, byte(0x88), b0)
+		if assert.True(t, len(payload) >= 2) {
+			assert.Equal(t, c.code, int(binary.BigEndian.Uint16(payload)))
+		}
+
+		err = <-errs
+		if assert.IsType(t, &WebSocketCloseError{}, err) {
+			assert.Equal(t, c.code, err.(*WebSocketCloseError).Code)
+		}
+
+		conn.Close()
+	}
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+}
+
+func TestAirWebSocketHugeFrameLength(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+	a.Config.WebSocketMaxMessageBytes = 0
+
+	errs := make(chan error, 1)
+	a.WebSocket("/", func(wsc *WebSocketConn) error {
+		_, b, err := wsc.ReadMessage()
+		if err == nil {
+			err = wsc.WriteBinary(b)
+		}
+		errs <- err
+		return err
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	for _, length := range []uint64{1 << 62, 1 << 36} {
+		conn, _, _, err := dialWebSocket(a.Config.Address, "/", nil)
+		assert.NoError(t, err)
+
+		frame := []byte{0x82, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6}
+		binary.BigEndian.PutUint64(frame[2:], length)
+		conn.Write(frame)
+		conn.Close()
+
+		assert.Equal(t, io.ErrUnexpectedEOF, <-errs)
+	}
+
+	conn, r, _, err := dialWebSocket(a.Config.Address, "/", nil)
+	assert.NoError(t, err)
+
+	writeWebSocketFrame(conn, 0x82, []byte("foo"))
+	b0, payload, _ := readWebSocketFrame(r)
+	assert.Equal(t, byte(0x82), b0)
+	assert.Equal(t, "foo", string(payload))
+	assert.NoError(t, <-errs)
+
+	conn.Close()
+
+	assert.NoError(t, a.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+}
+
+func TestAirShutdownWebSocket(t *testing.T) {
+	a := New()
+	a.Config.Address = freeAddress()
+
+	upgraded := make(chan struct{})
+	a.WebSocket("/", func(wsc *WebSocketConn) error {
+		close(upgraded)
+		for {
+			if _, _, err := wsc.ReadMessage(); err != nil {
+				return err
+			}
+		}
+	})
+
+	served := make(chan error)
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	conn, r, _, err := dialWebSocket(a.Config.Address, "/", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	<-upgraded
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- a.Shutdown(context.Background())
+	}()
+
+	b0, payload, _ := readWebSocketFrame(r)
+	assert.Equal(t, byte(0x88), b0)
+	assert.Equal(t, WebSocketCloseGoingAway, int(binary.BigEndian.Uint16(payload)))
+
+	writeWebSocketFrame(conn, 0x88, payload[:2])
+
+	assert.NoError(t, <-shutdown)
+	assert.NoError(t, <-served)
+
+	a = New()
+	a.Config.Address = freeAddress()
+	a.WebSocket("/", func(wsc *WebSocketConn) error {
+		for {
+			if _, _, err := wsc.ReadMessage(); err != nil {
+				return err
+			}
+		}
+	})
+
+	go func() {
+		served <- a.Serve()
+	}()
+
+	waitForServer(a.Config.Address)
+
+	conn, r, _, err = dialWebSocket(a.Config.Address, "/", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, a.Shutdown(ctx))
+	assert.NoError(t, <-served)
+
+	readWebSocketFrame(r)
+	_, _, err = readWebSocketFrame(r)
+	assert.Error(t, err)
+}
+
+func TestWebSocketDeflateRequested(t *testing.T) {
+	h := http.Header{}
+	assert.False(t, webSocketDeflateRequested(h))
+
+	h.Set(HeaderSecWebSocketExtensions, "foo, permessage-deflate")
+	assert.True(t, webSocketDeflateRequested(h))
+
+	h.Set(HeaderSecWebSocketExtensions, `permessage-deflate; server_max_window_bits="15"`)
+	assert.True(t, webSocketDeflateRequested(h))
+
+	h.Set(HeaderSecWebSocketExtensions, "permessage-deflate; server_max_window_bits=9")
+	assert.False(t, webSocketDeflateRequested(h))
+}
+
+func TestWebSocketCloseCodeValid(t *testing.T) {
+	assert.True(t, webSocketCloseCodeValid(WebSocketCloseNormalClosure))
+	assert.True(t, webSocketCloseCodeValid(4000))
+	assert.False(t, webSocketCloseCodeValid(WebSocketCloseNoStatusReceived))
+	assert.False(t, webSocketCloseCodeValid(WebSocketCloseAbnormalClosure))
+	assert.False(t, webSocketCloseCodeValid(999))
+	assert.False(t, webSocketCloseCodeValid(5000))
+}
+
+// dialWebSocket dials the WebSocket server at the address with the path and the header that
+// overrides the default handshake headers.
+func dialWebSocket(
+	address string,
+	path string,
+	header http.Header,
+) (net.Conn, *bufio.Reader, *http.Response, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	req, _ := http.NewRequest(GET, "http://"+address+path, nil)
+	req.Header.Set(HeaderConnection, "Upgrade")
+	req.Header.Set(HeaderUpgrade, "websocket")
+	req.Header.Set(HeaderSecWebSocketVersion, "13")
+	req.Header.Set(HeaderSecWebSocketKey, "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	r := bufio.NewReader(conn)
+
+	res, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	return conn, r, res, nil
+}
+
+// writeWebSocketFrame writes a masked WebSocket frame with the first byte b0 and the payload.
+func writeWebSocketFrame(w io.Writer, b0 byte, payload []byte) {
+	frame := []byte{b0, 0x80}
+	switch l := len(payload); {
+	case l <= 125:
+		frame[1] |= byte(l)
+	case l <= 0xffff:
+		frame[1] |= 126
+		frame = append(frame, byte(l>>8), byte(l))
+	default:
+		frame[1] |= 127
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[2:], uint64(l))
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	w.Write(frame)
+}
+
+// readWebSocketFrame reads an unmasked WebSocket frame from the r. It returns the first byte and
+// the payload of the frame.
+func readWebSocketFrame(r *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		b := make([]byte, 2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(b))
+	case 127:
+		b := make([]byte, 8)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(b)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], payload, nil
+}